@@ -0,0 +1,181 @@
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/option"
+	runv1 "google.golang.org/api/run/v1"
+	"google.golang.org/api/run/v2"
+	"strings"
+	"sync"
+)
+
+// CloudRunClient is the part of the Cloud Run Admin API v2 the checker relies on, plus the
+// locations listing only v1 offers. Parents and names are full resource names,
+// e.g. "projects/p/locations/us-central1/services/s".
+type CloudRunClient interface {
+	// ListLocations lists the regions Cloud Run is available in for a project, e.g. "projects/p".
+	ListLocations(ctx context.Context, name string, pageSize int64, pageToken string) (*runv1.ListLocationsResponse, error)
+	ListServices(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListServicesResponse, error)
+	ListRevisions(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListRevisionsResponse, error)
+	GetRevision(ctx context.Context, name string) (*run.GoogleCloudRunV2Revision, error)
+	GetService(ctx context.Context, name string) (*run.GoogleCloudRunV2Service, error)
+	// UpdateService replaces a service as read by GetService; the etag rejects concurrent changes.
+	// It returns once the new configuration is rolled out, or failed to.
+	UpdateService(ctx context.Context, service *run.GoogleCloudRunV2Service) error
+}
+
+// apiClient calls the regional Cloud Run endpoints, or a single endpoint when one is configured.
+type apiClient struct {
+	endpoint string
+
+	mu       sync.Mutex
+	services map[string]*run.Service
+	v1       *runv1.APIService
+}
+
+// NewAPIClient returns the production CloudRunClient.
+// An empty endpoint selects https://<region>-run.googleapis.com/ per request;
+// a plain http:// endpoint (e.g. a local fake) is called without authentication.
+func NewAPIClient(endpoint string) CloudRunClient {
+	return &apiClient{endpoint: endpoint, services: map[string]*run.Service{}}
+}
+
+func (c *apiClient) ListLocations(ctx context.Context, name string, pageSize int64, pageToken string) (*runv1.ListLocationsResponse, error) {
+	srv, err := c.v1Service(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return runv1.NewProjectsLocationsService(srv).List(name).PageSize(pageSize).PageToken(pageToken).Context(ctx).Do()
+}
+
+func (c *apiClient) ListServices(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListServicesResponse, error) {
+	srv, err := c.service(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return run.NewProjectsLocationsServicesService(srv).List(parent).PageSize(pageSize).PageToken(pageToken).Context(ctx).Do()
+}
+
+func (c *apiClient) ListRevisions(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListRevisionsResponse, error) {
+	srv, err := c.service(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+
+	return run.NewProjectsLocationsServicesRevisionsService(srv).List(parent).PageSize(pageSize).PageToken(pageToken).Context(ctx).Do()
+}
+
+func (c *apiClient) GetRevision(ctx context.Context, name string) (*run.GoogleCloudRunV2Revision, error) {
+	srv, err := c.service(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return run.NewProjectsLocationsServicesRevisionsService(srv).Get(name).Context(ctx).Do()
+}
+
+func (c *apiClient) GetService(ctx context.Context, name string) (*run.GoogleCloudRunV2Service, error) {
+	srv, err := c.service(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return run.NewProjectsLocationsServicesService(srv).Get(name).Context(ctx).Do()
+}
+
+func (c *apiClient) UpdateService(ctx context.Context, service *run.GoogleCloudRunV2Service) error {
+	srv, err := c.service(ctx, service.Name)
+	if err != nil {
+		return err
+	}
+
+	op, err := run.NewProjectsLocationsServicesService(srv).Patch(service.Name, service).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	// Wait returns when the operation is done or after the requested timeout, whichever comes first
+	operations := run.NewProjectsLocationsOperationsService(srv)
+	for !op.Done {
+		if op, err = operations.Wait(op.Name, &run.GoogleLongrunningWaitOperationRequest{Timeout: "30s"}).Context(ctx).Do(); err != nil {
+			return fmt.Errorf("waiting for the update of %s: %w", service.Name, err)
+		}
+	}
+	if op.Error != nil {
+		return fmt.Errorf("the update of %s failed: %s", service.Name, op.Error.Message)
+	}
+
+	return nil
+}
+
+// service returns the cached API service for the region of a resource name.
+func (c *apiClient) service(ctx context.Context, resourceName string) (*run.Service, error) {
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = "https://" + regionOf(resourceName) + "-run.googleapis.com/"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if srv, ok := c.services[endpoint]; ok {
+		return srv, nil
+	}
+
+	opts := []option.ClientOption{option.WithEndpoint(endpoint)}
+	if strings.HasPrefix(endpoint, "http://") {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+
+	// Create a new Cloud Run service client
+	// Make sure you have authenticated with appropriate permissions
+	srv, err := run.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("run.NewService: %w", err)
+	}
+	c.services[endpoint] = srv
+
+	return srv, nil
+}
+
+// v1Service returns the cached v1 API service, which is only used to list locations.
+// Locations are global, so the default endpoint is used unless one is configured.
+func (c *apiClient) v1Service(ctx context.Context) (*runv1.APIService, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.v1 != nil {
+		return c.v1, nil
+	}
+
+	var opts []option.ClientOption
+	if c.endpoint != "" {
+		opts = append(opts, option.WithEndpoint(c.endpoint))
+	}
+	if strings.HasPrefix(c.endpoint, "http://") {
+		opts = append(opts, option.WithoutAuthentication())
+	}
+
+	srv, err := runv1.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("run.NewService: %w", err)
+	}
+	c.v1 = srv
+
+	return srv, nil
+}
+
+// regionOf extracts the location from "projects/p/locations/<region>/...".
+func regionOf(resourceName string) string {
+	parts := strings.Split(resourceName, "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] == "locations" {
+			return parts[i+1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,366 @@
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	runv1 "google.golang.org/api/run/v1"
+	"google.golang.org/api/run/v2"
+	"net/http"
+	"revisions-checker/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeClient is an in-memory CloudRunClient for exercising the checker offline.
+// Tests script it with AddService, Deploy, DeployFailing, ShiftTraffic and FailNext.
+type FakeClient struct {
+	mu        sync.Mutex
+	services  map[string]*run.GoogleCloudRunV2Service
+	revisions map[string][]*run.GoogleCloudRunV2Revision
+	failures  map[string][]error
+	tags      map[string]string
+	now       time.Time
+}
+
+// Method names accepted by FakeClient.FailNext.
+const (
+	MethodListLocations = "ListLocations"
+	MethodListServices  = "ListServices"
+	MethodListRevisions = "ListRevisions"
+	MethodGetRevision   = "GetRevision"
+	MethodGetService    = "GetService"
+	MethodUpdateService = "UpdateService"
+)
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		services:  map[string]*run.GoogleCloudRunV2Service{},
+		revisions: map[string][]*run.GoogleCloudRunV2Revision{},
+		failures:  map[string][]error{},
+		tags:      map[string]string{},
+		now:       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// AddService registers an empty service and returns its full resource name.
+func (f *FakeClient) AddService(projectID, region, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fullName := fmt.Sprintf("projects/%s/locations/%s/services/%s", projectID, region, name)
+	f.services[fullName] = &run.GoogleCloudRunV2Service{
+		Name:       fullName,
+		CreateTime: f.tick().Format(time.RFC3339),
+		Uri:        fmt.Sprintf("https://%s-%s.a.run.app", name, region),
+	}
+
+	return fullName
+}
+
+// Deploy creates a new revision of the service running image and sends it 100% of the traffic.
+// It returns the full resource name of the new revision.
+func (f *FakeClient) Deploy(serviceName, image string) string {
+	return f.DeployContainers(serviceName, &run.GoogleCloudRunV2Container{Image: image})
+}
+
+// DeployContainers is Deploy for revisions with several containers, e.g. sidecars.
+func (f *FakeClient) DeployContainers(serviceName string, containers ...*run.GoogleCloudRunV2Container) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	revisions := f.revisions[serviceName]
+	revisionName := fmt.Sprintf("%s/revisions/%s-%05d", serviceName, utils.ExtractShortServiceName(serviceName), len(revisions)+1)
+	f.revisions[serviceName] = append(revisions, &run.GoogleCloudRunV2Revision{
+		Name:       revisionName,
+		CreateTime: f.tick().Format(time.RFC3339),
+		Containers: containers,
+	})
+	f.setTraffic(serviceName, map[string]int64{revisionName: 100})
+
+	return revisionName
+}
+
+// DeployFailing creates a new revision of the service that fails to become ready with message,
+// leaving the traffic split as it is. It returns the full resource name of the new revision.
+func (f *FakeClient) DeployFailing(serviceName, image, message string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	revisions := f.revisions[serviceName]
+	revisionName := fmt.Sprintf("%s/revisions/%s-%05d", serviceName, utils.ExtractShortServiceName(serviceName), len(revisions)+1)
+	f.revisions[serviceName] = append(revisions, &run.GoogleCloudRunV2Revision{
+		Name:       revisionName,
+		CreateTime: f.tick().Format(time.RFC3339),
+		Containers: []*run.GoogleCloudRunV2Container{{Image: image}},
+		Conditions: []*run.GoogleCloudRunV2Condition{
+			{Type: "Ready", State: "CONDITION_FAILED", Message: message},
+			{Type: "Active", State: "CONDITION_FAILED"},
+		},
+	})
+
+	return revisionName
+}
+
+// ShiftTraffic replaces the traffic split of a service; revisions are keyed by full or short name.
+// Revisions receiving a non-zero percentage become Active, all others stop being Active.
+func (f *FakeClient) ShiftTraffic(serviceName string, percents map[string]int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	full := map[string]int64{}
+	for name, percent := range percents {
+		if !strings.Contains(name, "/") {
+			name = serviceName + "/revisions/" + name
+		}
+		full[name] = percent
+	}
+	f.setTraffic(serviceName, full)
+}
+
+// TagRevision sets the traffic tag of a revision, keyed by full or short name, from the next traffic shift on.
+func (f *FakeClient) TagRevision(serviceName, revisionName, tag string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.Contains(revisionName, "/") {
+		revisionName = serviceName + "/revisions/" + revisionName
+	}
+	f.tags[revisionName] = tag
+}
+
+// FailNext makes the next call of method (one of the Method* constants) return err.
+// Calls queue up, so FailNext can script several consecutive failures.
+func (f *FakeClient) FailNext(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[method] = append(f.failures[method], err)
+}
+
+// ListLocations lists the regions the services of a project were added in.
+func (f *FakeClient) ListLocations(ctx context.Context, name string, pageSize int64, pageToken string) (*runv1.ListLocationsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodListLocations); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var regions []string
+	for serviceName := range f.services {
+		if region := regionOf(serviceName); strings.HasPrefix(serviceName, name+"/") && !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+
+	start, end, next, err := page(len(regions), pageSize, pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &runv1.ListLocationsResponse{NextPageToken: next}
+	for _, region := range regions[start:end] {
+		resp.Locations = append(resp.Locations, &runv1.Location{Name: name + "/locations/" + region, LocationId: region})
+	}
+
+	return resp, nil
+}
+
+func (f *FakeClient) ListServices(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListServicesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodListServices); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range f.services {
+		if strings.HasPrefix(name, parent+"/services/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	start, end, next, err := page(len(names), pageSize, pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &run.GoogleCloudRunV2ListServicesResponse{NextPageToken: next}
+	for _, name := range names[start:end] {
+		service := *f.services[name]
+		resp.Services = append(resp.Services, &service)
+	}
+
+	return resp, nil
+}
+
+func (f *FakeClient) ListRevisions(ctx context.Context, parent string, pageSize int64, pageToken string) (*run.GoogleCloudRunV2ListRevisionsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodListRevisions); err != nil {
+		return nil, err
+	}
+	if _, ok := f.services[parent]; !ok {
+		return nil, notFound(parent)
+	}
+
+	revisions := f.revisions[parent]
+	start, end, next, err := page(len(revisions), pageSize, pageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &run.GoogleCloudRunV2ListRevisionsResponse{NextPageToken: next}
+	for _, revision := range revisions[start:end] {
+		copied := *revision
+		resp.Revisions = append(resp.Revisions, &copied)
+	}
+
+	return resp, nil
+}
+
+func (f *FakeClient) GetRevision(ctx context.Context, name string) (*run.GoogleCloudRunV2Revision, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodGetRevision); err != nil {
+		return nil, err
+	}
+
+	serviceName, _, _ := strings.Cut(name, "/revisions/")
+	for _, revision := range f.revisions[serviceName] {
+		if revision.Name == name {
+			copied := *revision
+			return &copied, nil
+		}
+	}
+
+	return nil, notFound(name)
+}
+
+func (f *FakeClient) GetService(ctx context.Context, name string) (*run.GoogleCloudRunV2Service, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodGetService); err != nil {
+		return nil, err
+	}
+
+	service, ok := f.services[name]
+	if !ok {
+		return nil, notFound(name)
+	}
+	copied := *service
+
+	return &copied, nil
+}
+
+// UpdateService only applies the traffic split and tags of service.
+func (f *FakeClient) UpdateService(ctx context.Context, service *run.GoogleCloudRunV2Service) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.failure(MethodUpdateService); err != nil {
+		return err
+	}
+	if _, ok := f.services[service.Name]; !ok {
+		return notFound(service.Name)
+	}
+
+	percents := map[string]int64{}
+	for _, target := range service.Traffic {
+		name := service.Name + "/revisions/" + target.Revision
+		percents[name] += target.Percent
+		if target.Tag != "" {
+			f.tags[name] = target.Tag
+		}
+	}
+	f.setTraffic(service.Name, percents)
+
+	return nil
+}
+
+// setTraffic must be called with f.mu held.
+func (f *FakeClient) setTraffic(serviceName string, percents map[string]int64) {
+	service := f.services[serviceName]
+	if service == nil {
+		return
+	}
+
+	service.Traffic = nil
+	service.TrafficStatuses = nil
+	for _, revision := range f.revisions[serviceName] {
+		percent := percents[revision.Name]
+		state := "CONDITION_FAILED"
+		if percent > 0 {
+			state = "CONDITION_SUCCEEDED"
+			service.Traffic = append(service.Traffic, &run.GoogleCloudRunV2TrafficTarget{
+				Type:     "TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION",
+				Revision: utils.ExtractShortServiceName(revision.Name),
+				Percent:  percent,
+				Tag:      f.tags[revision.Name],
+			})
+			service.TrafficStatuses = append(service.TrafficStatuses, &run.GoogleCloudRunV2TrafficTargetStatus{
+				Type:     "TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION",
+				Revision: utils.ExtractShortServiceName(revision.Name),
+				Percent:  percent,
+				Tag:      f.tags[revision.Name],
+			})
+		}
+		conditions := []*run.GoogleCloudRunV2Condition{{Type: "Active", State: state}}
+		for _, condition := range revision.Conditions {
+			if condition.Type != "Active" {
+				conditions = append(conditions, condition)
+			}
+		}
+		revision.Conditions = conditions
+	}
+}
+
+// failure pops the next scripted error of method; must be called with f.mu held.
+func (f *FakeClient) failure(method string) error {
+	queued := f.failures[method]
+	if len(queued) == 0 {
+		return nil
+	}
+	f.failures[method] = queued[1:]
+
+	return queued[0]
+}
+
+// tick advances the fake clock so every created resource gets a distinct CreateTime.
+func (f *FakeClient) tick() time.Time {
+	f.now = f.now.Add(time.Minute)
+	return f.now
+}
+
+// page slices a listing of total items the way the API does, using the item offset as page token.
+func page(total int, pageSize int64, pageToken string) (start, end int, next string, err error) {
+	if pageToken != "" {
+		if start, err = strconv.Atoi(pageToken); err != nil || start < 0 || start > total {
+			return 0, 0, "", &googleapi.Error{Code: http.StatusBadRequest, Message: "invalid page token " + pageToken}
+		}
+	}
+
+	end = total
+	if pageSize > 0 && start+int(pageSize) < total {
+		end = start + int(pageSize)
+		next = strconv.Itoa(end)
+	}
+
+	return start, end, next, nil
+}
+
+func notFound(name string) error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: name + " not found"}
+}
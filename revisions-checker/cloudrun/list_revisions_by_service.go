@@ -3,9 +3,7 @@ package cloudrun
 import (
 	"context"
 	"fmt"
-	"google.golang.org/api/option"
 	"google.golang.org/api/run/v2"
-	"log"
 	. "revisions-checker/common"
 	. "revisions-checker/config"
 	"revisions-checker/utils"
@@ -13,33 +11,36 @@ import (
 	"time"
 )
 
-func ListRevisions(ctx context.Context, serviceName string, config Configuration) (activeRevisions, threeMostRecentRevisions []Revision) {
-
-	// Create a new Cloud Run service client
-	// Make sure you have authenticated with appropriate permissions
-	srv, err := run.NewService(ctx, option.WithEndpoint("https://"+config.Region+"-run.googleapis.com/"))
-	if err != nil {
-		log.Fatalf("run.NewService: %v", err)
-	}
-
+// ListRevisions returns the revisions of service that are active or receive traffic, and the config.RecentRevisions
+// most recent revisions. Traffic percentages and tags are taken from the traffic split of service.
+// An error wrapping ErrIncomplete means the revisions did not fit in MaxPages pages.
+func ListRevisions(ctx context.Context, client CloudRunClient, service Service, config Configuration) (activeRevisions, recentRevisions []Revision, err error) {
 	var serviceNameToSearch = config.ServiceName
-	if serviceName != "" {
-		serviceNameToSearch = serviceName
+	if service.Name != "" {
+		serviceNameToSearch = utils.ExtractShortServiceName(service.Name)
 	}
 
-	// Build the request to list revisions
-	revisionsService := run.NewProjectsLocationsServicesRevisionsService(srv)
-	call := revisionsService.List(fmt.Sprintf("projects/%s/locations/%s/services/%s", config.ProjectID, config.Region, serviceNameToSearch))
+	parent := fmt.Sprintf("projects/%s/locations/%s/services/%s", config.ProjectID, config.Region, serviceNameToSearch)
+
+	// Make the API requests to list revisions, one page at a time
+	var revisions []*run.GoogleCloudRunV2Revision
+	err = forEachPage(config, "revisions of "+parent, func(pageToken string) (string, error) {
+		resp, err := client.ListRevisions(ctx, parent, int64(config.PageSize), pageToken)
+		if err != nil {
+			return "", err
+		}
 
-	// Make the API request to list revisions
-	resp, err := call.Do()
+		revisions = append(revisions, resp.Revisions...)
+		return resp.NextPageToken, nil
+	})
 	if err != nil {
-		log.Fatalf("Failed to list revisions: %v", err)
+		return nil, nil, fmt.Errorf("failed to list revisions of %s: %w", parent, err)
 	}
 
 	var revisionsWithTime = []RevisionWithTime{}
+	traffic := trafficByRevision(service.Traffic)
 
-	for _, revision := range resp.Revisions {
+	for _, revision := range revisions {
 		var isActive bool
 		var creationTime time.Time
 		for _, condition := range revision.Conditions {
@@ -47,23 +48,22 @@ func ListRevisions(ctx context.Context, serviceName string, config Configuration
 				isActive = true
 			}
 		}
+		if traffic[utils.ExtractShortServiceName(revision.Name)].Percent > 0 {
+			isActive = true
+		}
 		if isActive {
 			creationTime, err = time.Parse(time.RFC3339, revision.CreateTime)
 			if err != nil {
-				log.Fatalf("Error parsing creation time: %v", err)
+				return nil, nil, fmt.Errorf("error parsing creation time of %s: %w", revision.Name, err)
 			}
 
-			activeRevisions = append(activeRevisions, Revision{
-				Name:         utils.ExtractShortServiceName(revision.Name),
-				CreationTime: creationTime,
-				Image:        revision.Containers[0].Image,
-			})
+			activeRevisions = append(activeRevisions, toRevision(revision, creationTime, traffic))
 			//activeRevisions = append(activeRevisions, revision)
 		}
 
 		creationTime, err = time.Parse(time.RFC3339, revision.CreateTime)
 		if err != nil {
-			log.Fatalf("Error parsing creation time: %v", err)
+			return nil, nil, fmt.Errorf("error parsing creation time of %s: %w", revision.Name, err)
 		}
 		revisionsWithTime = append(revisionsWithTime, RevisionWithTime{Revision: revision, Time: creationTime})
 	}
@@ -73,17 +73,64 @@ func ListRevisions(ctx context.Context, serviceName string, config Configuration
 		return revisionsWithTime[i].Time.After(revisionsWithTime[j].Time)
 	})
 
-	// Select the three most recent revisions
-	//var threeMostRecentRevisions []*run.GoogleCloudRunV2Revision
-	//var threeMostRecentRevisions []Revision
-	for i := 0; i < len(revisionsWithTime) && i < 3; i++ {
-		//threeMostRecentRevisions = append(threeMostRecentRevisions, revisionsWithTime[i].Revision)
-		threeMostRecentRevisions = append(threeMostRecentRevisions, Revision{
-			Name:         utils.ExtractShortServiceName(revisionsWithTime[i].Revision.Name),
-			CreationTime: revisionsWithTime[i].Time,
-			Image:        revisionsWithTime[i].Revision.Containers[0].Image,
-		})
+	// Select the most recent revisions
+	for i := 0; i < len(revisionsWithTime) && i < config.RecentRevisions; i++ {
+		recentRevisions = append(recentRevisions, toRevision(revisionsWithTime[i].Revision, revisionsWithTime[i].Time, traffic))
 	}
 
 	return
 }
+
+// trafficByRevision merges the entries of a traffic split per revision; a revision can appear
+// several times, e.g. once with a percentage and once more with a tag only.
+func trafficByRevision(allocations []TrafficAllocation) map[string]TrafficAllocation {
+	traffic := map[string]TrafficAllocation{}
+	for _, allocation := range allocations {
+		merged := traffic[allocation.Revision]
+		merged.Revision = allocation.Revision
+		merged.Percent += allocation.Percent
+		if allocation.Tag != "" {
+			if merged.Tag != "" {
+				merged.Tag += ","
+			}
+			merged.Tag += allocation.Tag
+		}
+		traffic[allocation.Revision] = merged
+	}
+	return traffic
+}
+
+func toRevision(revision *run.GoogleCloudRunV2Revision, creationTime time.Time, traffic map[string]TrafficAllocation) Revision {
+	name := utils.ExtractShortServiceName(revision.Name)
+	result := Revision{
+		Name:           name,
+		CreationTime:   creationTime,
+		TrafficPercent: traffic[name].Percent,
+		TrafficTag:     traffic[name].Tag,
+	}
+
+	for _, condition := range revision.Conditions {
+		if condition.Type == "Ready" && condition.State == "CONDITION_FAILED" {
+			result.Failure = condition.Message
+			if result.Failure == "" {
+				result.Failure = condition.Reason
+			}
+		}
+	}
+
+	for _, container := range revision.Containers {
+		c := Container{Name: container.Name, Image: container.Image}
+		for _, port := range container.Ports {
+			c.Ports = append(c.Ports, port.ContainerPort)
+		}
+		if container.Resources != nil {
+			c.Resources = container.Resources.Limits
+		}
+		result.Containers = append(result.Containers, c)
+	}
+	if len(result.Containers) > 0 {
+		result.Image = result.Containers[0].Image
+	}
+
+	return result
+}
@@ -2,41 +2,66 @@ package cloudrun
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"google.golang.org/api/option"
 	"google.golang.org/api/run/v2"
-	"log"
 	. "revisions-checker/common"
 	. "revisions-checker/config"
 )
 
-func ListServices(ctx context.Context, config Configuration) ([]Service, error) {
-  // Create a new Cloud Run service client
-  srv, err := run.NewService(ctx, option.WithEndpoint("https://"+config.Region+"-run.googleapis.com/"))
-  if err != nil {
-    log.Fatalf("run.NewService: %v", err)
-    return nil, err
-  }
-
-  // Build the request to list services
-  servicesService := run.NewProjectsLocationsServicesService(srv)
-  call := servicesService.List(fmt.Sprintf("projects/%s/locations/%s", config.ProjectID, config.Region))
-
-  // Make the API request to list services
-  resp, err := call.Do()
-  if err != nil {
-    log.Fatalf("Failed to list services: %v", err)
-    return nil, err
-  }
-
-  var services []Service
-  for _, service := range resp.Services {
-    services = append(services, Service{
-      Name:         service.Name,
-      CreationTime: service.CreateTime,
-      URL:          service.Uri,
-    })
-  }
-
-  return services, nil
+// ListServices returns the services of the project and region of config. When the listing stops at
+// the MaxPages cap, the services listed so far are returned along with an error wrapping ErrIncomplete.
+func ListServices(ctx context.Context, client CloudRunClient, config Configuration) ([]Service, error) {
+	parent := fmt.Sprintf("projects/%s/locations/%s", config.ProjectID, config.Region)
+
+	// Make the API requests to list services, one page at a time
+	var services []Service
+	err := forEachPage(config, "services of "+parent, func(pageToken string) (string, error) {
+		resp, err := client.ListServices(ctx, parent, int64(config.PageSize), pageToken)
+		if err != nil {
+			return "", err
+		}
+
+		for _, service := range resp.Services {
+			services = append(services, Service{
+				Name:         service.Name,
+				CreationTime: service.CreateTime,
+				URL:          service.Uri,
+				ProjectID:    config.ProjectID,
+				Region:       config.Region,
+				Traffic:      trafficOf(service),
+				Labels:       service.Labels,
+			})
+		}
+
+		return resp.NextPageToken, nil
+	})
+	if errors.Is(err, ErrIncomplete) {
+		return services, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services of %s: %w", parent, err)
+	}
+
+	return services, nil
+}
+
+// trafficOf returns the traffic split the service actually serves, falling back to the
+// requested split for revisions the service has not reported a status for yet.
+func trafficOf(service *run.GoogleCloudRunV2Service) []TrafficAllocation {
+	var traffic []TrafficAllocation
+	for _, status := range service.TrafficStatuses {
+		traffic = append(traffic, TrafficAllocation{Revision: status.Revision, Percent: status.Percent, Tag: status.Tag})
+	}
+	if len(traffic) > 0 {
+		return traffic
+	}
+
+	for _, target := range service.Traffic {
+		if target.Revision != "" {
+			traffic = append(traffic, TrafficAllocation{Revision: target.Revision, Percent: target.Percent, Tag: target.Tag})
+		}
+	}
+
+	return traffic
 }
@@ -0,0 +1,30 @@
+package cloudrun
+
+import (
+	"errors"
+	"fmt"
+	. "revisions-checker/config"
+)
+
+// ErrIncomplete is returned, wrapped, when a listing stopped at the MaxPages safety cap
+// while the API still had more pages; whatever was fetched so far is partial.
+var ErrIncomplete = errors.New("results are incomplete")
+
+// forEachPage calls fetch with successive page tokens until the API stops returning a NextPageToken
+// or the configured MaxPages safety cap is reached, in which case it returns ErrIncomplete.
+func forEachPage(config Configuration, what string, fetch func(pageToken string) (nextPageToken string, err error)) error {
+	pageToken := ""
+	for page := 1; ; page++ {
+		nextPageToken, err := fetch(pageToken)
+		if err != nil {
+			return err
+		}
+		if nextPageToken == "" {
+			return nil
+		}
+		if config.MaxPages > 0 && page >= config.MaxPages {
+			return fmt.Errorf("stopped listing %s after %d pages (max_pages): %w", what, page, ErrIncomplete)
+		}
+		pageToken = nextPageToken
+	}
+}
@@ -0,0 +1,144 @@
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/api/run/v2"
+	"net/http"
+	"net/http/httptest"
+	. "revisions-checker/common"
+	. "revisions-checker/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	testParent  = "projects/p/locations/us-central1"
+	testService = testParent + "/services/api"
+)
+
+// newPagedServer serves the services of testParent and the revisions of testService in pages of
+// pageSize items, the page token being the index of the first item of the next page.
+func newPagedServer(t *testing.T, services, revisions, pageSize int) (*httptest.Server, *int) {
+	requests := 0
+	page := func(r *http.Request, total int) (start, end int, next string) {
+		requests++
+		start, _ = strconv.Atoi(r.URL.Query().Get("pageToken"))
+		end = start + pageSize
+		if end < total {
+			next = strconv.Itoa(end)
+		} else {
+			end = total
+		}
+		return
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp interface{}
+		switch r.URL.Path {
+		case "/v2/" + testParent + "/services":
+			start, end, next := page(r, services)
+			list := &run.GoogleCloudRunV2ListServicesResponse{NextPageToken: next}
+			for i := start; i < end; i++ {
+				list.Services = append(list.Services, &run.GoogleCloudRunV2Service{Name: fmt.Sprintf("%s/services/s%d", testParent, i)})
+			}
+			resp = list
+		case "/v2/" + testService + "/revisions":
+			start, end, next := page(r, revisions)
+			list := &run.GoogleCloudRunV2ListRevisionsResponse{NextPageToken: next}
+			for i := start; i < end; i++ {
+				list.Revisions = append(list.Revisions, &run.GoogleCloudRunV2Revision{
+					Name:       fmt.Sprintf("%s/revisions/api-%05d", testService, i),
+					CreateTime: time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC).Format(time.RFC3339),
+					Conditions: []*run.GoogleCloudRunV2Condition{{Type: "Active", State: "CONDITION_SUCCEEDED"}},
+				})
+			}
+			resp = list
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func testConfig(maxPages int) Configuration {
+	return Configuration{ProjectID: "p", Region: "us-central1", ServiceName: "api", PageSize: 2, MaxPages: maxPages, RecentRevisions: 2}
+}
+
+func TestListServicesFollowsPages(t *testing.T) {
+	server, requests := newPagedServer(t, 5, 0, 2)
+
+	services, err := ListServices(context.Background(), NewAPIClient(server.URL), testConfig(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *requests != 3 {
+		t.Errorf("got %d requests, want 3", *requests)
+	}
+	if len(services) != 5 {
+		t.Fatalf("got %d services, want 5", len(services))
+	}
+	for i, service := range services {
+		if want := fmt.Sprintf("%s/services/s%d", testParent, i); service.Name != want {
+			t.Errorf("services[%d] = %s, want %s", i, service.Name, want)
+		}
+	}
+}
+
+func TestListRevisionsFollowsPages(t *testing.T) {
+	server, requests := newPagedServer(t, 0, 6, 2)
+
+	active, recent, err := ListRevisions(context.Background(), NewAPIClient(server.URL), Service{Name: testService}, testConfig(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *requests != 3 {
+		t.Errorf("got %d requests, want 3", *requests)
+	}
+	if len(active) != 6 {
+		t.Errorf("got %d active revisions, want 6", len(active))
+	}
+	if names := revisionNames(recent); fmt.Sprint(names) != "[api-00005 api-00004]" {
+		t.Errorf("recent revisions = %v, want the two newest across pages", names)
+	}
+}
+
+func TestMaxPages(t *testing.T) {
+	server, requests := newPagedServer(t, 5, 6, 2)
+	client := NewAPIClient(server.URL)
+
+	services, err := ListServices(context.Background(), client, testConfig(2))
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("ListServices error = %v, want ErrIncomplete", err)
+	}
+	if len(services) != 4 {
+		t.Errorf("got %d services, want the 4 of the first 2 pages", len(services))
+	}
+	if *requests != 2 {
+		t.Errorf("got %d requests, want 2", *requests)
+	}
+
+	if _, _, err := ListRevisions(context.Background(), client, Service{Name: testService}, testConfig(2)); !errors.Is(err, ErrIncomplete) {
+		t.Errorf("ListRevisions error = %v, want ErrIncomplete", err)
+	}
+}
+
+func revisionNames(revisions []Revision) []string {
+	var names []string
+	for _, revision := range revisions {
+		names = append(names, revision.Name)
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+package cloudrun
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"google.golang.org/api/run/v2"
+	"path"
+	. "revisions-checker/common"
+	"strconv"
+)
+
+// GetRevisionSpec fetches the full revision of service and flattens the settings worth comparing.
+func GetRevisionSpec(ctx context.Context, client CloudRunClient, service Service, revisionName string) (RevisionSpec, error) {
+	revision, err := client.GetRevision(ctx, service.Name+"/revisions/"+revisionName)
+	if err != nil {
+		return RevisionSpec{}, fmt.Errorf("failed to get revision %s: %w", revisionName, err)
+	}
+
+	return revisionSpec(revision), nil
+}
+
+func revisionSpec(revision *run.GoogleCloudRunV2Revision) RevisionSpec {
+	settings := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+
+	set("service-account", revision.ServiceAccount)
+	set("timeout", revision.Timeout)
+	set("execution-environment", revision.ExecutionEnvironment)
+	if revision.MaxInstanceRequestConcurrency > 0 {
+		set("concurrency", strconv.FormatInt(revision.MaxInstanceRequestConcurrency, 10))
+	}
+	if revision.Scaling != nil {
+		set("min-instances", strconv.FormatInt(revision.Scaling.MinInstanceCount, 10))
+		if revision.Scaling.MaxInstanceCount > 0 {
+			set("max-instances", strconv.FormatInt(revision.Scaling.MaxInstanceCount, 10))
+		}
+	}
+	if revision.VpcAccess != nil {
+		set("vpc-connector", revision.VpcAccess.Connector)
+		set("vpc-egress", revision.VpcAccess.Egress)
+	}
+
+	for i, container := range revision.Containers {
+		name := container.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i)
+		}
+		if container.Resources != nil {
+			for resource, limit := range container.Resources.Limits {
+				set("limits/"+name+"/"+resource, limit)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueSource != nil && env.ValueSource.SecretKeyRef != nil {
+				ref := env.ValueSource.SecretKeyRef
+				set("secret/"+name+"/"+env.Name, secretRef(ref.Secret, ref.Version))
+				continue
+			}
+			settings["env/"+name+"/"+env.Name] = maskedValue(env.Value)
+		}
+	}
+
+	for _, volume := range revision.Volumes {
+		if volume.Secret != nil {
+			set("secret-volume/"+volume.Name, path.Base(volume.Secret.Secret))
+		}
+	}
+
+	return RevisionSpec{Revision: revision.Name, Settings: settings}
+}
+
+func secretRef(secret, version string) string {
+	if version == "" {
+		version = "latest"
+	}
+	return path.Base(secret) + ":" + version
+}
+
+// maskKey keys the env var value digests. Specs are only compared within a run, so a random key
+// works and keeps the digests from being matched against guessed values.
+var maskKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// maskedValue hides an env var value behind a keyed digest that only reveals whether it changed.
+func maskedValue(value string) string {
+	mac := hmac.New(sha256.New, maskKey)
+	mac.Write([]byte(value))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))
+}
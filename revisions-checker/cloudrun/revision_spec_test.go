@@ -0,0 +1,28 @@
+package cloudrun
+
+import (
+	"google.golang.org/api/run/v2"
+	"strings"
+	"testing"
+)
+
+func TestRevisionSpecMasksEnvValues(t *testing.T) {
+	spec := func(value string) string {
+		revision := &run.GoogleCloudRunV2Revision{Containers: []*run.GoogleCloudRunV2Container{{
+			Name: "app",
+			Env:  []*run.GoogleCloudRunV2EnvVar{{Name: "TOKEN", Value: value}},
+		}}}
+		return revisionSpec(revision).Settings["env/app/TOKEN"]
+	}
+
+	masked := spec("hunter2")
+	if strings.Contains(masked, "hunter2") || masked == "" {
+		t.Errorf("env value masked as %q", masked)
+	}
+	if spec("hunter2") != masked {
+		t.Error("the same value was masked differently")
+	}
+	if spec("hunter3") == masked {
+		t.Error("a changed value was masked the same")
+	}
+}
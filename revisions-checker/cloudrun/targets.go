@@ -0,0 +1,67 @@
+package cloudrun
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "revisions-checker/config"
+)
+
+// ResolveTargets returns the project/region pairs to monitor.
+// With DiscoverRegions set, every Cloud Run region of each configured project is listed instead of the configured regions.
+// Projects whose regions could not be listed are returned in failures, keyed by project ID, while the others are still
+// resolved; when the listing stopped at the MaxPages cap, the regions listed so far are kept as well.
+func ResolveTargets(ctx context.Context, client CloudRunClient, config Configuration) (targets []Target, failures map[string]error) {
+	if !config.DiscoverRegions {
+		return config.ConfiguredTargets(), nil
+	}
+
+	seen := map[string]bool{}
+	for _, target := range config.ConfiguredTargets() {
+		if seen[target.ProjectID] {
+			continue
+		}
+		seen[target.ProjectID] = true
+
+		regions, err := listRegions(ctx, client, config, target.ProjectID)
+		for _, region := range regions {
+			targets = append(targets, Target{ProjectID: target.ProjectID, Region: region})
+		}
+		if err != nil {
+			if failures == nil {
+				failures = map[string]error{}
+			}
+			failures[target.ProjectID] = err
+		}
+	}
+
+	return targets, failures
+}
+
+// listRegions returns the regions Cloud Run is available in for a project.
+func listRegions(ctx context.Context, client CloudRunClient, config Configuration, projectID string) ([]string, error) {
+	name := "projects/" + projectID
+
+	// Make the API requests to list the regions, one page at a time
+	var regions []string
+	err := forEachPage(config, "regions of "+name, func(pageToken string) (string, error) {
+		resp, err := client.ListLocations(ctx, name, int64(config.PageSize), pageToken)
+		if err != nil {
+			return "", err
+		}
+
+		for _, location := range resp.Locations {
+			regions = append(regions, location.LocationId)
+		}
+
+		return resp.NextPageToken, nil
+	})
+	if errors.Is(err, ErrIncomplete) {
+		return regions, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list regions of project %s: %w", projectID, err)
+	}
+
+	return regions, nil
+}
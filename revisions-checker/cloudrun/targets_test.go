@@ -0,0 +1,93 @@
+package cloudrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	runv1 "google.golang.org/api/run/v1"
+	"net/http"
+	"net/http/httptest"
+	. "revisions-checker/config"
+	"testing"
+)
+
+func TestResolveTargetsDiscoversRegions(t *testing.T) {
+	client := NewFakeClient()
+	client.AddService("a", "us-central1", "api")
+	client.AddService("a", "europe-west1", "api")
+	client.AddService("a", "europe-west1", "web")
+	client.AddService("b", "asia-east1", "api")
+	client.AddService("c", "us-east1", "api")
+
+	config := Configuration{DiscoverRegions: true, PageSize: 1, Targets: []Target{{ProjectID: "a"}, {ProjectID: "b", Region: "ignored"}, {ProjectID: "a", Region: "us-central1"}}}
+	targets, failures := ResolveTargets(context.Background(), client, config)
+	if failures != nil {
+		t.Fatalf("failures = %v", failures)
+	}
+	if got, want := fmt.Sprint(targets), "[a/europe-west1 a/us-central1 b/asia-east1]"; got != want {
+		t.Errorf("targets = %s, want %s", got, want)
+	}
+}
+
+func TestResolveTargetsWithoutDiscovery(t *testing.T) {
+	config := Configuration{ProjectID: "a", Region: "us-central1"}
+	targets, failures := ResolveTargets(context.Background(), NewFakeClient(), config)
+	if failures != nil || fmt.Sprint(targets) != "[a/us-central1]" {
+		t.Errorf("got %v, %v, want the configured target", targets, failures)
+	}
+}
+
+func TestResolveTargetsReportsFailuresPerProject(t *testing.T) {
+	client := NewFakeClient()
+	client.AddService("a", "us-central1", "api")
+	client.AddService("b", "europe-west1", "api")
+	failure := errors.New("permission denied")
+	client.FailNext(MethodListLocations, failure)
+
+	config := Configuration{DiscoverRegions: true, Targets: []Target{{ProjectID: "a"}, {ProjectID: "b"}}}
+	targets, failures := ResolveTargets(context.Background(), client, config)
+	if got, want := fmt.Sprint(targets), "[b/europe-west1]"; got != want {
+		t.Errorf("targets = %s, want %s", got, want)
+	}
+	if len(failures) != 1 || !errors.Is(failures["a"], failure) {
+		t.Errorf("failures = %v, want the failure of project a only", failures)
+	}
+}
+
+func TestResolveTargetsKeepsIncompleteListings(t *testing.T) {
+	client := NewFakeClient()
+	client.AddService("a", "us-central1", "api")
+	client.AddService("a", "europe-west1", "api")
+	client.AddService("a", "asia-east1", "api")
+
+	config := Configuration{DiscoverRegions: true, ProjectID: "a", PageSize: 1, MaxPages: 2}
+	targets, failures := ResolveTargets(context.Background(), client, config)
+	if !errors.Is(failures["a"], ErrIncomplete) {
+		t.Errorf("failures = %v, want ErrIncomplete for project a", failures)
+	}
+	if got, want := fmt.Sprint(targets), "[a/asia-east1 a/europe-west1]"; got != want {
+		t.Errorf("targets = %s, want the regions of the first 2 pages %s", got, want)
+	}
+}
+
+func TestAPIClientListsLocationsAtTheEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/projects/p/locations" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&runv1.ListLocationsResponse{Locations: []*runv1.Location{{LocationId: "us-central1"}, {LocationId: "europe-west1"}}})
+	}))
+	defer server.Close()
+
+	config := Configuration{DiscoverRegions: true, ProjectID: "p"}
+	targets, failures := ResolveTargets(context.Background(), NewAPIClient(server.URL), config)
+	if failures != nil {
+		t.Fatalf("failures = %v", failures)
+	}
+	if got, want := fmt.Sprint(targets), "[p/us-central1 p/europe-west1]"; got != want {
+		t.Errorf("targets = %s, want %s", got, want)
+	}
+}
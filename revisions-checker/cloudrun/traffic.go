@@ -0,0 +1,38 @@
+package cloudrun
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/api/run/v2"
+	"revisions-checker/utils"
+)
+
+// RouteAllTraffic sends 100% of the traffic of a service to one revision, e.g. to roll back.
+// Tagged targets are kept at 0% so their URLs keep working.
+func RouteAllTraffic(ctx context.Context, client CloudRunClient, serviceName, revision string) error {
+	service, err := client.GetService(ctx, serviceName)
+	if err != nil {
+		return fmt.Errorf("reading service %s: %w", serviceName, err)
+	}
+
+	revision = utils.ExtractShortServiceName(revision)
+	target := &run.GoogleCloudRunV2TrafficTarget{Type: "TRAFFIC_TARGET_ALLOCATION_TYPE_REVISION", Revision: revision, Percent: 100}
+	traffic := []*run.GoogleCloudRunV2TrafficTarget{target}
+	for _, current := range service.Traffic {
+		if current.Tag == "" {
+			continue
+		}
+		if current.Revision == revision && target.Tag == "" {
+			target.Tag = current.Tag
+			continue
+		}
+		traffic = append(traffic, &run.GoogleCloudRunV2TrafficTarget{Type: current.Type, Revision: current.Revision, Tag: current.Tag})
+	}
+	service.Traffic = traffic
+
+	if err := client.UpdateService(ctx, service); err != nil {
+		return fmt.Errorf("updating the traffic of service %s: %w", serviceName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+package common
+
+import "errors"
+
+// ErrSnapshotNotFound is returned by state stores for a key that was never written,
+// i.e. a service that is checked for the first time.
+var ErrSnapshotNotFound = errors.New("snapshot not found")
@@ -29,11 +29,71 @@ type RevisionWithTime struct {
 type Revision struct {
 	Name         string
 	CreationTime time.Time
-	Image        string
+	// Image is the image of the first container, kept for messages and state written before Containers existed
+	Image          string
+	Containers     []Container
+	TrafficPercent int64
+	// TrafficTag holds the comma-separated traffic tags pointing at the revision
+	TrafficTag string
+	// Failure is the message of the revision's failed Ready condition, empty while it is healthy
+	Failure string
+}
+
+// Container is one container of a revision, sidecars included.
+type Container struct {
+	Name  string
+	Image string
+	Ports []int64
+	// Resources holds the resource limits, e.g. "cpu" and "memory"
+	Resources map[string]string
 }
 
 type Service struct {
 	Name         string
 	CreationTime string
 	URL          string
+	ProjectID    string
+	Region       string
+	Traffic      []TrafficAllocation
+	Labels       map[string]string
+}
+
+// TrafficAllocation is one entry of the traffic split actually served by a service.
+type TrafficAllocation struct {
+	Revision string
+	Percent  int64
+	Tag      string
+}
+
+// RevisionSpec is the flattened configuration of a revision, keyed by setting, e.g.
+// "timeout", "limits/app/memory" or "env/app/DB_HOST". Env var values are replaced by a
+// digest keyed per run so changes can be detected without revealing them.
+type RevisionSpec struct {
+	Revision string
+	Settings map[string]string
+}
+
+// HistoryEntry is one append-only record of what happened to a service: the revision an event
+// was about and the traffic split served right after it.
+// Entries for actions taken by users, e.g. acknowledging a deploy, name the Actor and carry no traffic split.
+type HistoryEntry struct {
+	Time     time.Time
+	Event    string
+	Revision Revision
+	Serving  []TrafficAllocation
+	// Actor identifies who took the action, e.g. "slack:U024BE7LH"
+	Actor string
+	// Note explains the outcome of the action, e.g. why a rollback was refused
+	Note string
+}
+
+// PostedMessage is a chat message a notifier posted about a revision and may update or reply to later,
+// e.g. a Slack message identified by its channel and timestamp.
+type PostedMessage struct {
+	Channel   string
+	Timestamp string
+	Posted    time.Time
+	// Event is the event the message shows, JSON encoded by the notifier, so the message can be rendered
+	// again with only the traffic split updated
+	Event []byte `json:",omitempty"`
 }
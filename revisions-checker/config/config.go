@@ -1,10 +1,46 @@
 package config
 
+// First run policies decide what is sent when a service has no saved state yet.
+const (
+	// FirstRunSeed saves the current revisions without notifying.
+	FirstRunSeed = "seed"
+	// FirstRunNotifyAll notifies about every active revision as if it were new.
+	FirstRunNotifyAll = "notify-all"
+	// FirstRunAnnounce sends a single "now monitoring service X" message.
+	FirstRunAnnounce = "announce"
+)
+
+// Backends accepted by the state_backend setting.
+const (
+	BackendFirestore = "firestore"
+	BackendFile      = "file"
+	BackendBolt      = "bolt"
+)
+
 type Configuration struct {
-	ProjectID                                 string
-	ServiceName                               string
-	Region                                    string
-	SlackWebhookURL                           string
-	MostRecentRevisionsFirebaseDocumentPrefix string
-	ActiveRevisionsFirebaseDocumentPrefix     string
+	ProjectID                                 string           `json:"project_id" yaml:"project_id"`
+	ServiceName                               string           `json:"service_name" yaml:"service_name"`
+	Region                                    string           `json:"region" yaml:"region"`
+	SlackWebhookURL                           string           `json:"slack_webhook_url" yaml:"slack_webhook_url"`
+	MostRecentRevisionsFirebaseDocumentPrefix string           `json:"most_recent_revisions_document_prefix" yaml:"most_recent_revisions_document_prefix"`
+	ActiveRevisionsFirebaseDocumentPrefix     string           `json:"active_revisions_document_prefix" yaml:"active_revisions_document_prefix"`
+	HistoryDocumentPrefix                     string           `json:"history_document_prefix" yaml:"history_document_prefix"`
+	MessagesDocumentPrefix                    string           `json:"messages_document_prefix" yaml:"messages_document_prefix"`
+	CloudRunEndpoint                          string           `json:"cloud_run_endpoint" yaml:"cloud_run_endpoint"`
+	FirestoreProjectID                        string           `json:"firestore_project_id" yaml:"firestore_project_id"`
+	StateBackend                              string           `json:"state_backend" yaml:"state_backend"`
+	StatePath                                 string           `json:"state_path" yaml:"state_path"`
+	StateCollection                           string           `json:"state_collection" yaml:"state_collection"`
+	FirstRunPolicy                            string           `json:"first_run_policy" yaml:"first_run_policy"`
+	SecretManagerEndpoint                     string           `json:"secret_manager_endpoint" yaml:"secret_manager_endpoint"`
+	Targets                                   []Target         `json:"targets" yaml:"targets"`
+	DiscoverRegions                           bool             `json:"discover_regions" yaml:"discover_regions"`
+	PageSize                                  int              `json:"page_size" yaml:"page_size"`
+	MaxPages                                  int              `json:"max_pages" yaml:"max_pages"`
+	RecentRevisions                           int              `json:"recent_revisions" yaml:"recent_revisions"`
+	Notifiers                                 []NotifierConfig `json:"notifiers" yaml:"notifiers"`
+	SlackSigningSecret                        string           `json:"slack_signing_secret" yaml:"slack_signing_secret"`
+	// RollbackAllowlist maps services to the Slack user IDs allowed to roll them back. Keys are "<project>/<region>/<service>",
+	// a short service name, which matches the services of that name in every project and region, or "*" for every service
+	RollbackAllowlist map[string][]string `json:"rollback_allowlist" yaml:"rollback_allowlist"`
 }
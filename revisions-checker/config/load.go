@@ -0,0 +1,239 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"revisions-checker/secrets"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable that points at a YAML or JSON config file.
+const ConfigFileEnv = "RCN_CONFIG_FILE"
+
+// binding ties a Configuration field to its config file key and RCN_* environment variable.
+type binding struct {
+	key      string
+	env      string
+	required bool
+	field    func(c *Configuration) *string
+}
+
+var bindings = []binding{
+	{"project_id", "RCN_PROJECT_ID", false, func(c *Configuration) *string { return &c.ProjectID }},
+	{"service_name", "RCN_SERVICE_NAME", false, func(c *Configuration) *string { return &c.ServiceName }},
+	{"region", "RCN_REGION", false, func(c *Configuration) *string { return &c.Region }},
+	{"slack_webhook_url", "RCN_SLACK_WEBHOOK_URL", false, func(c *Configuration) *string { return &c.SlackWebhookURL }},
+	{"most_recent_revisions_document_prefix", "RCN_MOST_RECENT_REVISIONS_DOCUMENT_PREFIX", true, func(c *Configuration) *string { return &c.MostRecentRevisionsFirebaseDocumentPrefix }},
+	{"active_revisions_document_prefix", "RCN_ACTIVE_REVISIONS_DOCUMENT_PREFIX", true, func(c *Configuration) *string { return &c.ActiveRevisionsFirebaseDocumentPrefix }},
+	{"history_document_prefix", "RCN_HISTORY_DOCUMENT_PREFIX", true, func(c *Configuration) *string { return &c.HistoryDocumentPrefix }},
+	{"messages_document_prefix", "RCN_MESSAGES_DOCUMENT_PREFIX", true, func(c *Configuration) *string { return &c.MessagesDocumentPrefix }},
+	{"cloud_run_endpoint", "RCN_CLOUD_RUN_ENDPOINT", false, func(c *Configuration) *string { return &c.CloudRunEndpoint }},
+	{"firestore_project_id", "RCN_FIRESTORE_PROJECT_ID", false, func(c *Configuration) *string { return &c.FirestoreProjectID }},
+	{"state_backend", "RCN_STATE_BACKEND", true, func(c *Configuration) *string { return &c.StateBackend }},
+	{"state_path", "RCN_STATE_PATH", false, func(c *Configuration) *string { return &c.StatePath }},
+	{"state_collection", "RCN_STATE_COLLECTION", true, func(c *Configuration) *string { return &c.StateCollection }},
+	{"first_run_policy", "RCN_FIRST_RUN_POLICY", true, func(c *Configuration) *string { return &c.FirstRunPolicy }},
+	{"slack_signing_secret", "RCN_SLACK_SIGNING_SECRET", false, func(c *Configuration) *string { return &c.SlackSigningSecret }},
+	{"secret_manager_endpoint", "RCN_SECRET_MANAGER_ENDPOINT", false, func(c *Configuration) *string { return &c.SecretManagerEndpoint }},
+}
+
+// envSetting parses an RCN_* environment variable into a Configuration field that is not a plain string.
+type envSetting struct {
+	env   string
+	parse func(c *Configuration, value string) error
+}
+
+var envSettings = []envSetting{
+	{"RCN_TARGETS", func(c *Configuration, value string) (err error) {
+		c.Targets, err = ParseTargets(value)
+		return
+	}},
+	{"RCN_DISCOVER_REGIONS", func(c *Configuration, value string) (err error) {
+		c.DiscoverRegions, err = strconv.ParseBool(value)
+		return
+	}},
+	{"RCN_PAGE_SIZE", func(c *Configuration, value string) (err error) {
+		c.PageSize, err = strconv.Atoi(value)
+		return
+	}},
+	{"RCN_MAX_PAGES", func(c *Configuration, value string) (err error) {
+		c.MaxPages, err = strconv.Atoi(value)
+		return
+	}},
+	{"RCN_RECENT_REVISIONS", func(c *Configuration, value string) (err error) {
+		c.RecentRevisions, err = strconv.Atoi(value)
+		return
+	}},
+	// RCN_NOTIFIERS holds the notifiers as a JSON array, e.g. [{"name":"ops","type":"slack","webhook_url":"..."}]
+	{"RCN_NOTIFIERS", func(c *Configuration, value string) error {
+		c.Notifiers = nil
+		return json.Unmarshal([]byte(value), &c.Notifiers)
+	}},
+	// RCN_ROLLBACK_ALLOWLIST holds the allowlist as a JSON object, e.g. {"my-project/us-central1/api":["U024BE7LH"]}
+	{"RCN_ROLLBACK_ALLOWLIST", func(c *Configuration, value string) error {
+		c.RollbackAllowlist = nil
+		return json.Unmarshal([]byte(value), &c.RollbackAllowlist)
+	}},
+}
+
+// Defaults returns the values used for any setting that is neither in the config file nor in the environment.
+func Defaults() Configuration {
+	return Configuration{
+		MostRecentRevisionsFirebaseDocumentPrefix: "mostrecent.revisions.",
+		ActiveRevisionsFirebaseDocumentPrefix:     "active.revisions.",
+		HistoryDocumentPrefix:                     "history.revisions.",
+		MessagesDocumentPrefix:                    "messages.",
+		StateBackend:                              BackendFirestore,
+		StateCollection:                           "revisions",
+		FirstRunPolicy:                            FirstRunSeed,
+		PageSize:                                  100,
+		MaxPages:                                  50,
+		RecentRevisions:                           3,
+	}
+}
+
+// Load reads, resolves and validates the configuration.
+// Sources are applied in increasing order of precedence: Defaults, the file named
+// by RCN_CONFIG_FILE and finally the RCN_* environment variables.
+func Load() (Configuration, error) {
+	config, err := Read()
+	if err != nil {
+		return config, err
+	}
+
+	if err := config.ResolveSecrets(context.Background()); err != nil {
+		return config, err
+	}
+
+	return config, config.Validate()
+}
+
+// Read merges the configuration sources like Load, without resolving secrets or validating.
+func Read() (Configuration, error) {
+	config := Defaults()
+
+	if path := os.Getenv(ConfigFileEnv); path != "" {
+		if err := mergeFile(&config, path); err != nil {
+			return config, err
+		}
+	}
+
+	for _, b := range bindings {
+		if value, ok := os.LookupEnv(b.env); ok {
+			*b.field(&config) = value
+		}
+	}
+
+	for _, setting := range envSettings {
+		if value, ok := os.LookupEnv(setting.env); ok {
+			if err := setting.parse(&config, value); err != nil {
+				return config, fmt.Errorf("parsing %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// ResolveSecrets replaces every setting written as a secret reference
+// (secret://, env:// or file://) with the value it points at.
+func (c *Configuration) ResolveSecrets(ctx context.Context) error {
+	registry := secrets.NewDefaultRegistry(c.SecretManagerEndpoint)
+
+	for _, b := range bindings {
+		field := b.field(c)
+		value, err := registry.Resolve(ctx, *field)
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.key, err)
+		}
+		*field = value
+	}
+
+	for i := range c.Notifiers {
+		fields := c.Notifiers[i].secretFields()
+		for key, field := range fields {
+			value, err := registry.Resolve(ctx, *field)
+			if err != nil {
+				return fmt.Errorf("notifiers[%d].%s: %w", i, key, err)
+			}
+			*field = value
+		}
+		c.Notifiers[i].setSecretFields(fields)
+	}
+
+	return nil
+}
+
+// Validate reports every required setting that is missing.
+func (c Configuration) Validate() error {
+	var errs []error
+	for _, b := range bindings {
+		if b.required && strings.TrimSpace(*b.field(&c)) == "" {
+			errs = append(errs, fmt.Errorf("missing %q (set %s or %q in the config file)", b.key, b.env, b.key))
+		}
+	}
+	errs = append(errs, c.validateTargets()...)
+	errs = append(errs, c.validateNotifiers()...)
+	switch c.StateBackend {
+	case "", BackendFirestore:
+	case BackendFile, BackendBolt:
+		if c.StatePath == "" {
+			errs = append(errs, fmt.Errorf("missing \"state_path\" (set RCN_STATE_PATH), required by the %s state backend", c.StateBackend))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown \"state_backend\" %q, expected firestore, file or bolt", c.StateBackend))
+	}
+	switch c.FirstRunPolicy {
+	case "", FirstRunSeed, FirstRunNotifyAll, FirstRunAnnounce:
+	default:
+		errs = append(errs, fmt.Errorf("unknown \"first_run_policy\" %q, expected %s, %s or %s", c.FirstRunPolicy, FirstRunSeed, FirstRunNotifyAll, FirstRunAnnounce))
+	}
+	if c.PageSize < 0 {
+		errs = append(errs, fmt.Errorf("\"page_size\" must not be negative, got %d", c.PageSize))
+	}
+	if c.MaxPages < 0 {
+		errs = append(errs, fmt.Errorf("\"max_pages\" must not be negative (0 disables the cap), got %d", c.MaxPages))
+	}
+	if c.RecentRevisions < 1 {
+		errs = append(errs, fmt.Errorf("\"recent_revisions\" must be at least 1, got %d", c.RecentRevisions))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func mergeFile(config *Configuration, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file %q: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	default:
+		return fmt.Errorf("config file %q: unsupported extension, expected .json, .yaml or .yml", path)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %q: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable the loader reads for the duration of the test.
+func clearEnv(t *testing.T) {
+	names := []string{ConfigFileEnv}
+	for _, b := range bindings {
+		names = append(names, b.env)
+	}
+	for _, setting := range envSettings {
+		names = append(names, setting.env)
+	}
+
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := `project_id: from-file
+region: europe-west1
+slack_webhook_url: https://hooks.slack.com/services/file
+page_size: 20
+rollback_allowlist:
+  api: [U1]
+`
+	jsonFile := `{"project_id": "from-json", "region": "us-east1", "slack_webhook_url": "https://hooks.slack.com/services/json", "recent_revisions": 5}`
+
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(c Configuration) bool
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"RCN_PROJECT_ID": "p", "RCN_REGION": "us-central1", "RCN_SLACK_WEBHOOK_URL": "https://hooks.slack.com/services/env"},
+			check: func(c Configuration) bool {
+				return c.StateBackend == "firestore" && c.PageSize == 100 && c.FirstRunPolicy == FirstRunSeed
+			},
+		},
+		{
+			name: "yaml file over defaults",
+			file: writeFile(t, "config.yaml", yamlFile),
+			check: func(c Configuration) bool {
+				return c.ProjectID == "from-file" && c.PageSize == 20 && c.MaxPages == 50 && reflect.DeepEqual(c.RollbackAllowlist, map[string][]string{"api": {"U1"}})
+			},
+		},
+		{
+			name: "json file over defaults",
+			file: writeFile(t, "config.json", jsonFile),
+			check: func(c Configuration) bool {
+				return c.ProjectID == "from-json" && c.RecentRevisions == 5 && c.PageSize == 100
+			},
+		},
+		{
+			name: "environment over file",
+			file: writeFile(t, "config.yaml", yamlFile),
+			env: map[string]string{"RCN_PROJECT_ID": "from-env", "RCN_PAGE_SIZE": "7",
+				"RCN_ROLLBACK_ALLOWLIST": `{"p/us-central1/api":["U2","U3"]}`},
+			check: func(c Configuration) bool {
+				return c.ProjectID == "from-env" && c.Region == "europe-west1" && c.PageSize == 7 &&
+					reflect.DeepEqual(c.RollbackAllowlist, map[string][]string{"p/us-central1/api": {"U2", "U3"}})
+			},
+		},
+		{
+			name: "structured environment values",
+			env: map[string]string{"RCN_TARGETS": "a/us-central1, b/", "RCN_DISCOVER_REGIONS": "true",
+				"RCN_NOTIFIERS": `[{"name":"ops","type":"teams","webhook_url":"https://example.webhook.office.com/x"}]`},
+			check: func(c Configuration) bool {
+				return reflect.DeepEqual(c.Targets, []Target{{"a", "us-central1"}, {"b", ""}}) && c.DiscoverRegions &&
+					len(c.Notifiers) == 1 && c.Notifiers[0].Type == NotifierTeams
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			if test.file != "" {
+				t.Setenv(ConfigFileEnv, test.file)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			config, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(config) {
+				t.Errorf("unexpected configuration %+v", config)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		// want lists parts of the error, which reports every problem at once
+		want []string
+	}{
+		{
+			name: "nothing configured",
+			want: []string{`missing "project_id" (set RCN_PROJECT_ID`, `missing "region" (set RCN_REGION`, "no notification destination"},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{"RCN_PROJECT_ID": "p", "RCN_REGION": "r", "RCN_SLACK_WEBHOOK_URL": "https://x",
+				"RCN_STATE_BACKEND": "redis", "RCN_FIRST_RUN_POLICY": "shout", "RCN_RECENT_REVISIONS": "0", "RCN_STATE_COLLECTION": " "},
+			want: []string{`unknown "state_backend" "redis"`, `unknown "first_run_policy" "shout"`, `"recent_revisions" must be at least 1`, `missing "state_collection"`},
+		},
+		{
+			name: "file backend without a path",
+			env:  map[string]string{"RCN_PROJECT_ID": "p", "RCN_REGION": "r", "RCN_SLACK_WEBHOOK_URL": "https://x", "RCN_STATE_BACKEND": "file"},
+			want: []string{`missing "state_path" (set RCN_STATE_PATH), required by the file state backend`},
+		},
+		{
+			name: "unparsable number",
+			env:  map[string]string{"RCN_PAGE_SIZE": "many"},
+			want: []string{"parsing RCN_PAGE_SIZE"},
+		},
+		{
+			name: "unparsable allowlist",
+			env:  map[string]string{"RCN_ROLLBACK_ALLOWLIST": `["U1"]`},
+			want: []string{"parsing RCN_ROLLBACK_ALLOWLIST"},
+		},
+		{
+			name: "invalid target",
+			env:  map[string]string{"RCN_TARGETS": "a/b/c"},
+			want: []string{`parsing RCN_TARGETS: invalid target "a/b/c"`},
+		},
+		{
+			name: "unknown file key",
+			file: writeFile(t, "config.yaml", "project: p\n"),
+			want: []string{"parsing config file", "field project not found"},
+		},
+		{
+			name: "unsupported file",
+			file: writeFile(t, "config.toml", "project_id = 'p'\n"),
+			want: []string{"unsupported extension"},
+		},
+		{
+			name: "missing file",
+			file: filepath.Join(t.TempDir(), "missing.yaml"),
+			want: []string{"reading config file"},
+		},
+		{
+			name: "unresolvable secret",
+			env:  map[string]string{"RCN_PROJECT_ID": "p", "RCN_REGION": "r", "RCN_SLACK_WEBHOOK_URL": "env://RCN_TEST_UNSET_WEBHOOK"},
+			want: []string{`slack_webhook_url: resolving secret "env://RCN_TEST_UNSET_WEBHOOK"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			if test.file != "" {
+				t.Setenv(ConfigFileEnv, test.file)
+			}
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := Load()
+			if err == nil {
+				t.Fatal("the configuration was accepted")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("RCN_PROJECT_ID", "p")
+	t.Setenv("RCN_REGION", "r")
+	t.Setenv("RCN_NOTIFIERS", `[{"name":"ops","type":"slack","bot_token":"env://RCN_TEST_BOT_TOKEN","channel":"#deploys"}]`)
+	t.Setenv("RCN_TEST_BOT_TOKEN", "xoxb-123")
+
+	config, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Notifiers[0].BotToken != "xoxb-123" {
+		t.Errorf("bot_token = %q, want the resolved secret", config.Notifiers[0].BotToken)
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Notifier types accepted by the type setting of a notifier.
+const (
+	NotifierSlack      = "slack"
+	NotifierTeams      = "teams"
+	NotifierGoogleChat = "google-chat"
+	NotifierWebhook    = "webhook"
+	NotifierPagerDuty  = "pagerduty"
+	NotifierEmail      = "email"
+	NotifierDiscord    = "discord"
+	NotifierMattermost = "mattermost"
+	NotifierTelegram   = "telegram"
+)
+
+var notifierTypes = []string{NotifierSlack, NotifierTeams, NotifierGoogleChat, NotifierWebhook, NotifierPagerDuty, NotifierEmail,
+	NotifierDiscord, NotifierMattermost, NotifierTelegram}
+
+// NotifierConfig is one named notification destination. Every event is sent to all of them.
+type NotifierConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+	// WebhookURL is where events are posted; for PagerDuty and Telegram it optionally replaces the API endpoint
+	WebhookURL string `json:"webhook_url" yaml:"webhook_url"`
+	// Secret signs the requests of webhook notifiers
+	Secret string `json:"secret" yaml:"secret"`
+	// Headers are added to every request of webhook notifiers; values may be secret references
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	// Timeout bounds each request of webhook notifiers, e.g. "5s"
+	Timeout string `json:"timeout" yaml:"timeout"`
+	// RoutingKey is the PagerDuty integration key used for services no route matches
+	RoutingKey string `json:"routing_key" yaml:"routing_key"`
+	// Routes pick a PagerDuty routing key per service or service label, first match wins
+	Routes []Route `json:"routes" yaml:"routes"`
+	// SMTPAddress is the "host:port" of the SMTP server of email notifiers
+	SMTPAddress string `json:"smtp_address" yaml:"smtp_address"`
+	// RequireTLS makes email notifiers refuse servers that do not offer STARTTLS; true unless set to false,
+	// e.g. for a relay on localhost
+	RequireTLS *bool `json:"require_tls" yaml:"require_tls"`
+	// Username and Password authenticate email notifiers, over TLS only
+	Username string `json:"username" yaml:"username"`
+	Password string `json:"password" yaml:"password"`
+	From     string `json:"from" yaml:"from"`
+	// Recipients maps event kinds (or "default" for every kind) to the addresses emailed about them
+	Recipients map[string][]string `json:"recipients" yaml:"recipients"`
+	// BotToken authenticates Slack and Telegram bots, ChatID selects the chat of Telegram notifiers
+	BotToken string `json:"bot_token" yaml:"bot_token"`
+	ChatID   string `json:"chat_id" yaml:"chat_id"`
+	// Channel is the channel Slack notifiers post to with a BotToken instead of a WebhookURL,
+	// keeping one message per deploy
+	Channel string `json:"channel" yaml:"channel"`
+	// Interactive adds Acknowledge and Roll back buttons to the new revision messages of Slack notifiers;
+	// the interactivity request URL of the Slack app must point at the SlackActions function
+	Interactive bool `json:"interactive" yaml:"interactive"`
+	// Templates maps event kinds (or "default" for every kind) to text/template files replacing the built-in wording
+	Templates map[string]string `json:"templates" yaml:"templates"`
+}
+
+// Route sends the events of the services matching Service (short name) or Label ("key=value") to RoutingKey.
+type Route struct {
+	Service    string `json:"service" yaml:"service"`
+	Label      string `json:"label" yaml:"label"`
+	RoutingKey string `json:"routing_key" yaml:"routing_key"`
+}
+
+// ConfiguredNotifiers returns Notifiers, or a single Slack notifier named "slack" posting to
+// SlackWebhookURL when no notifiers are listed.
+func (c Configuration) ConfiguredNotifiers() []NotifierConfig {
+	if len(c.Notifiers) > 0 {
+		return c.Notifiers
+	}
+	if c.SlackWebhookURL == "" {
+		return nil
+	}
+
+	return []NotifierConfig{{Name: NotifierSlack, Type: NotifierSlack, WebhookURL: c.SlackWebhookURL}}
+}
+
+// secretFields returns the settings of a notifier that may hold secret references.
+func (n *NotifierConfig) secretFields() map[string]*string {
+	fields := map[string]*string{
+		"webhook_url": &n.WebhookURL,
+		"secret":      &n.Secret,
+		"routing_key": &n.RoutingKey,
+		"password":    &n.Password,
+		"bot_token":   &n.BotToken,
+	}
+	// Routes may be shared with other copies of the configuration
+	n.Routes = append([]Route(nil), n.Routes...)
+	for i := range n.Routes {
+		fields[fmt.Sprintf("routes[%d].routing_key", i)] = &n.Routes[i].RoutingKey
+	}
+	for name := range n.Headers {
+		value := n.Headers[name]
+		fields["headers."+name] = &value
+	}
+
+	return fields
+}
+
+// setSecretFields writes back the values of fields obtained from secretFields.
+func (n *NotifierConfig) setSecretFields(fields map[string]*string) {
+	headers := map[string]string{}
+	for key, value := range fields {
+		if name, ok := strings.CutPrefix(key, "headers."); ok {
+			headers[name] = *value
+		}
+	}
+	if len(headers) > 0 {
+		n.Headers = headers
+	}
+}
+
+// Redacted returns a copy of the configuration without webhook URLs and other secret settings,
+// safe to expose e.g. to notification templates.
+func (c Configuration) Redacted() Configuration {
+	c.SlackWebhookURL = ""
+	c.SlackSigningSecret = ""
+	c.Notifiers = append([]NotifierConfig{}, c.Notifiers...)
+	for i := range c.Notifiers {
+		fields := c.Notifiers[i].secretFields()
+		for _, field := range fields {
+			*field = ""
+		}
+		c.Notifiers[i].setSecretFields(fields)
+	}
+
+	return c
+}
+
+func (c Configuration) validateNotifiers() []error {
+	notifiers := c.ConfiguredNotifiers()
+	if len(notifiers) == 0 {
+		return []error{fmt.Errorf("no notification destination (set RCN_SLACK_WEBHOOK_URL, or list \"notifiers\" / RCN_NOTIFIERS)")}
+	}
+
+	var errs []error
+	names := map[string]bool{}
+	for i, n := range notifiers {
+		if strings.TrimSpace(n.Name) == "" {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: missing \"name\"", i))
+		} else if names[n.Name] {
+			errs = append(errs, fmt.Errorf("notifiers[%d]: duplicate name %q", i, n.Name))
+		}
+		names[n.Name] = true
+		if n.Interactive && n.Type != NotifierSlack {
+			errs = append(errs, fmt.Errorf("notifiers[%d] (%s): \"interactive\" is only supported by slack notifiers", i, n.Name))
+		}
+
+		switch n.Type {
+		case NotifierSlack:
+			if n.BotToken != "" && n.Channel == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"channel\" to post to with \"bot_token\"", i, n.Name))
+			}
+			if n.BotToken == "" && n.WebhookURL == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"webhook_url\" or \"bot_token\"", i, n.Name))
+			}
+		case NotifierTeams, NotifierGoogleChat, NotifierDiscord, NotifierMattermost:
+			if n.WebhookURL == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"webhook_url\"", i, n.Name))
+			}
+		case NotifierPagerDuty:
+			if n.RoutingKey == "" && len(n.Routes) == 0 {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"routing_key\" or \"routes\"", i, n.Name))
+			}
+			for j, route := range n.Routes {
+				if route.RoutingKey == "" || (route.Service == "") == (route.Label == "") || (route.Label != "" && !strings.Contains(route.Label, "=")) {
+					errs = append(errs, fmt.Errorf("notifiers[%d] (%s): routes[%d] needs a \"routing_key\" and either a \"service\" or a \"label\" written as key=value", i, n.Name, j))
+				}
+			}
+		case NotifierEmail:
+			if _, port, err := net.SplitHostPort(n.SMTPAddress); err != nil || port == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): invalid \"smtp_address\" %q, expected host:port", i, n.Name, n.SMTPAddress))
+			}
+			if _, err := mail.ParseAddress(n.From); err != nil {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): invalid \"from\" %q: %v", i, n.Name, n.From, err))
+			}
+			if len(n.Recipients) == 0 {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"recipients\"", i, n.Name))
+			}
+			for kind, addresses := range n.Recipients {
+				if _, err := mail.ParseAddressList(strings.Join(addresses, ", ")); err != nil {
+					errs = append(errs, fmt.Errorf("notifiers[%d] (%s): invalid recipients of %q: %v", i, n.Name, kind, err))
+				}
+			}
+		case NotifierTelegram:
+			if n.BotToken == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"bot_token\"", i, n.Name))
+			}
+			if n.ChatID == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"chat_id\"", i, n.Name))
+			}
+		case NotifierWebhook:
+			if n.WebhookURL == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"webhook_url\"", i, n.Name))
+			}
+			if n.Secret == "" {
+				errs = append(errs, fmt.Errorf("notifiers[%d] (%s): missing \"secret\" to sign requests with", i, n.Name))
+			}
+			if n.Timeout != "" {
+				if timeout, err := time.ParseDuration(n.Timeout); err != nil || timeout <= 0 {
+					errs = append(errs, fmt.Errorf("notifiers[%d] (%s): invalid \"timeout\" %q, expected a positive duration such as \"10s\"", i, n.Name, n.Timeout))
+				}
+			}
+		default:
+			errs = append(errs, fmt.Errorf("notifiers[%d] (%s): unknown \"type\" %q, expected one of %s", i, n.Name, n.Type, strings.Join(notifierTypes, ", ")))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target is one project/region pair to monitor.
+// Region may be left empty when DiscoverRegions is set.
+type Target struct {
+	ProjectID string `json:"project_id" yaml:"project_id"`
+	Region    string `json:"region" yaml:"region"`
+}
+
+func (t Target) String() string {
+	if t.Region == "" {
+		return t.ProjectID
+	}
+	return fmt.Sprintf("%s/%s", t.ProjectID, t.Region)
+}
+
+// ConfiguredTargets returns Targets, or the single ProjectID/Region pair when no targets are listed.
+func (c Configuration) ConfiguredTargets() []Target {
+	if len(c.Targets) > 0 {
+		return c.Targets
+	}
+
+	return []Target{{ProjectID: c.ProjectID, Region: c.Region}}
+}
+
+// ForTarget returns a copy of the configuration scoped to a single project and region.
+// State keeps living in FirestoreProjectID, which defaults to the first configured project.
+func (c Configuration) ForTarget(target Target) Configuration {
+	if c.FirestoreProjectID == "" {
+		c.FirestoreProjectID = c.ConfiguredTargets()[0].ProjectID
+	}
+	c.ProjectID = target.ProjectID
+	c.Region = target.Region
+	c.Targets = []Target{target}
+
+	return c
+}
+
+// ParseTargets parses a comma-separated list of "project/region" pairs, e.g. "a/us-central1,b/europe-west1".
+// The region may be omitted ("project" or "project/") when regions are discovered.
+func ParseTargets(value string) ([]Target, error) {
+	var targets []Target
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		projectID, region, _ := strings.Cut(item, "/")
+		if projectID == "" || strings.Contains(region, "/") {
+			return nil, fmt.Errorf("invalid target %q, expected \"project/region\"", item)
+		}
+		targets = append(targets, Target{ProjectID: projectID, Region: region})
+	}
+
+	return targets, nil
+}
+
+func (c Configuration) validateTargets() []error {
+	if len(c.Targets) == 0 {
+		var errs []error
+		if c.ProjectID == "" {
+			errs = append(errs, fmt.Errorf("missing \"project_id\" (set RCN_PROJECT_ID, or list \"targets\" / RCN_TARGETS)"))
+		}
+		if c.Region == "" && !c.DiscoverRegions {
+			errs = append(errs, fmt.Errorf("missing \"region\" (set RCN_REGION, or enable \"discover_regions\" / RCN_DISCOVER_REGIONS)"))
+		}
+		return errs
+	}
+
+	var errs []error
+	for i, target := range c.Targets {
+		if target.ProjectID == "" {
+			errs = append(errs, fmt.Errorf("targets[%d]: missing \"project_id\"", i))
+		}
+		if target.Region == "" && !c.DiscoverRegions {
+			errs = append(errs, fmt.Errorf("targets[%d]: missing \"region\" (or enable \"discover_regions\")", i))
+		}
+	}
+
+	return errs
+}
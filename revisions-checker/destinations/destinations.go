@@ -0,0 +1,84 @@
+package destinations
+
+import (
+	"fmt"
+	. "revisions-checker/config"
+	"revisions-checker/discord"
+	"revisions-checker/email"
+	"revisions-checker/googlechat"
+	"revisions-checker/mattermost"
+	"revisions-checker/notify"
+	"revisions-checker/pagerduty"
+	"revisions-checker/slack"
+	. "revisions-checker/state"
+	"revisions-checker/teams"
+	"revisions-checker/telegram"
+	"revisions-checker/templates"
+	"revisions-checker/webhook"
+	"strings"
+	"time"
+)
+
+// New builds one notifier per configured destination and a Dispatcher fanning events out to all of them.
+// store keeps what notifiers need to remember between runs, e.g. the Slack messages to update.
+func New(config Configuration, store StateStore) (*notify.Dispatcher, error) {
+	var notifiers []notify.Named
+	for _, n := range config.ConfiguredNotifiers() {
+		set, err := templates.Load(n.Templates, config)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", n.Name, err)
+		}
+		notifier, err := newNotifier(n, set, config, store)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", n.Name, err)
+		}
+		notifiers = append(notifiers, notify.Named{Name: n.Name, Notifier: notifier})
+	}
+
+	return notify.NewDispatcher(notifiers...), nil
+}
+
+func newNotifier(n NotifierConfig, set *templates.Set, config Configuration, store StateStore) (notify.Notifier, error) {
+	switch n.Type {
+	case NotifierSlack:
+		if n.BotToken != "" {
+			// Every notifier keeps its own messages, so that two bots posting the same deploy don't share threads
+			return slack.Bot{Token: n.BotToken, Channel: n.Channel, Templates: set, Store: store,
+				KeyPrefix: config.MessagesDocumentPrefix + n.Name + ".", Interactive: n.Interactive}, nil
+		}
+		return slack.Notifier{WebhookURL: n.WebhookURL, Templates: set, Interactive: n.Interactive}, nil
+	case NotifierTeams:
+		return teams.Notifier{WebhookURL: n.WebhookURL, Templates: set}, nil
+	case NotifierGoogleChat:
+		return googlechat.Notifier{WebhookURL: n.WebhookURL, Templates: set}, nil
+	case NotifierDiscord:
+		return discord.Notifier{WebhookURL: n.WebhookURL, Templates: set}, nil
+	case NotifierMattermost:
+		return mattermost.Notifier{WebhookURL: n.WebhookURL, Templates: set}, nil
+	case NotifierTelegram:
+		return telegram.Notifier{Endpoint: n.WebhookURL, BotToken: n.BotToken, ChatID: n.ChatID, Templates: set}, nil
+	case NotifierPagerDuty:
+		// Events API payloads are structured, so templates do not apply
+		return pagerduty.Notifier{Endpoint: n.WebhookURL, RoutingKey: n.RoutingKey, Routes: n.Routes}, nil
+	case NotifierEmail:
+		for kind := range n.Recipients {
+			if !templates.IsKind(kind) {
+				return nil, fmt.Errorf("recipients: unknown event kind %q, expected one of %s", kind, strings.Join(templates.Kinds, ", "))
+			}
+		}
+		return email.Notifier{Address: n.SMTPAddress, Username: n.Username, Password: n.Password, From: n.From,
+			RequireTLS: n.RequireTLS == nil || *n.RequireTLS, Recipients: n.Recipients, Templates: set, Configuration: config}, nil
+	case NotifierWebhook:
+		// The payload is a fixed schema, so templates do not apply
+		var timeout time.Duration
+		if n.Timeout != "" {
+			var err error
+			if timeout, err = time.ParseDuration(n.Timeout); err != nil {
+				return nil, fmt.Errorf("invalid timeout: %w", err)
+			}
+		}
+		return webhook.Notifier{URL: n.WebhookURL, Secret: n.Secret, Headers: n.Headers, Timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", n.Type)
+	}
+}
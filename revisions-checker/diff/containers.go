@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"fmt"
+	. "revisions-checker/common"
+)
+
+// ContainerChange describes a container whose image differs between two revisions.
+// PreviousImage is empty for an added container, CurrentImage for a removed one.
+type ContainerChange struct {
+	Name          string
+	PreviousImage string
+	CurrentImage  string
+}
+
+// Containers lists the containers whose image changed from previous to current, matched by name.
+func Containers(previous, current Revision) []ContainerChange {
+	previousImages := containerImages(previous)
+	currentImages := containerImages(current)
+
+	var changes []ContainerChange
+	for _, name := range containerNames(current) {
+		if previousImage := previousImages[name]; previousImage != currentImages[name] {
+			changes = append(changes, ContainerChange{Name: name, PreviousImage: previousImage, CurrentImage: currentImages[name]})
+		}
+	}
+	for _, name := range containerNames(previous) {
+		if _, ok := currentImages[name]; !ok {
+			changes = append(changes, ContainerChange{Name: name, PreviousImage: previousImages[name]})
+		}
+	}
+
+	return changes
+}
+
+// containerKey names unnamed containers by position so single-container revisions still match.
+func containerKey(container Container, index int) string {
+	if container.Name != "" {
+		return container.Name
+	}
+	return fmt.Sprintf("#%d", index)
+}
+
+func containerNames(revision Revision) []string {
+	var names []string
+	for i, container := range revision.Containers {
+		names = append(names, containerKey(container, i))
+	}
+	return names
+}
+
+func containerImages(revision Revision) map[string]string {
+	images := map[string]string{}
+	for i, container := range revision.Containers {
+		images[containerKey(container, i)] = container.Image
+	}
+	return images
+}
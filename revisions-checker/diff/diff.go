@@ -0,0 +1,109 @@
+package diff
+
+import (
+	. "revisions-checker/common"
+)
+
+// RevisionChange pairs two states of the same revision.
+type RevisionChange struct {
+	Previous Revision
+	Current  Revision
+}
+
+// RevisionDiff is the difference between two lists of revisions, matched by name.
+type RevisionDiff struct {
+	Added   []Revision
+	Removed []Revision
+	Changed []RevisionChange
+}
+
+func (d RevisionDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ServiceDiff holds the differences of both tracked lists of a service.
+type ServiceDiff struct {
+	Active RevisionDiff
+	Recent RevisionDiff
+	// PreviousActive and CurrentActive are the compared active lists, kept to classify
+	// rollbacks and to show traffic splits.
+	PreviousActive []Revision
+	CurrentActive  []Revision
+}
+
+func (d ServiceDiff) Empty() bool {
+	return d.Active.Empty() && d.Recent.Empty()
+}
+
+// Revisions compares two lists of revisions by name.
+func Revisions(previous, current []Revision) RevisionDiff {
+	var d RevisionDiff
+
+	previousByName := byName(previous)
+	currentByName := byName(current)
+
+	for _, curr := range current {
+		prev, ok := previousByName[curr.Name]
+		if !ok {
+			d.Added = append(d.Added, curr)
+		} else if !sameRevision(prev, curr) {
+			d.Changed = append(d.Changed, RevisionChange{Previous: prev, Current: curr})
+		}
+	}
+	for _, prev := range previous {
+		if _, ok := currentByName[prev.Name]; !ok {
+			d.Removed = append(d.Removed, prev)
+		}
+	}
+
+	return d
+}
+
+// Compare compares the saved and the live state of a service.
+func Compare(previousActive, currentActive, previousRecent, currentRecent []Revision) ServiceDiff {
+	return ServiceDiff{
+		Active:         Revisions(previousActive, currentActive),
+		Recent:         Revisions(previousRecent, currentRecent),
+		PreviousActive: previousActive,
+		CurrentActive:  currentActive,
+	}
+}
+
+// RecentWindow trims the saved and the live "most recent" lists, both newest first, to the configured size,
+// so that lowering the number of tracked revisions is not mistaken for revisions being deleted.
+func RecentWindow(previous, current []Revision, size int) ([]Revision, []Revision) {
+	return trim(previous, size), trim(current, size)
+}
+
+func trim(revisions []Revision, size int) []Revision {
+	if len(revisions) > size {
+		return revisions[:size]
+	}
+	return revisions
+}
+
+func byName(revisions []Revision) map[string]Revision {
+	m := make(map[string]Revision, len(revisions))
+	for _, r := range revisions {
+		m[r.Name] = r
+	}
+	return m
+}
+
+// sameRevision reports whether nothing tracked about the revision has changed.
+func sameRevision(a, b Revision) bool {
+	return sameDeployment(a, b) && sameTraffic(a, b) && a.Failure == b.Failure
+}
+
+// sameDeployment compares what was deployed, ignoring how traffic is routed to it.
+// State saved before containers were tracked only has the image to compare.
+func sameDeployment(a, b Revision) bool {
+	if len(a.Containers) == 0 || len(b.Containers) == 0 {
+		return a.Image == b.Image
+	}
+	return len(Containers(a, b)) == 0
+}
+
+func sameTraffic(a, b Revision) bool {
+	return a.TrafficPercent == b.TrafficPercent && a.TrafficTag == b.TrafficTag
+}
@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"fmt"
+	. "revisions-checker/common"
+	"testing"
+)
+
+func TestRecentWindow(t *testing.T) {
+	tests := []struct {
+		name                      string
+		previous, current         []string
+		size                      int
+		wantPrevious, wantCurrent string
+	}{
+		{"first revision", nil, []string{"a"}, 3, "[]", "[a]"},
+		{"fewer than size", []string{"b", "a"}, []string{"c", "b", "a"}, 3, "[b a]", "[c b a]"},
+		{"size lowered", []string{"c", "b", "a"}, []string{"c", "b", "a"}, 2, "[c b]", "[c b]"},
+		{"current longer", []string{"c", "b", "a"}, []string{"e", "d", "c", "b"}, 3, "[c b a]", "[e d c]"},
+	}
+
+	for _, test := range tests {
+		previous, current := RecentWindow(revisions(test.previous...), revisions(test.current...), test.size)
+		if got := fmt.Sprint(names(previous)); got != test.wantPrevious {
+			t.Errorf("%s: previous = %s, want %s", test.name, got, test.wantPrevious)
+		}
+		if got := fmt.Sprint(names(current)); got != test.wantCurrent {
+			t.Errorf("%s: current = %s, want %s", test.name, got, test.wantCurrent)
+		}
+	}
+}
+
+func revisions(names ...string) []Revision {
+	var revisions []Revision
+	for _, name := range names {
+		revisions = append(revisions, Revision{Name: name})
+	}
+	return revisions
+}
+
+func names(revisions []Revision) []string {
+	names := []string{}
+	for _, revision := range revisions {
+		names = append(names, revision.Name)
+	}
+	return names
+}
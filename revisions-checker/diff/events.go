@@ -0,0 +1,151 @@
+package diff
+
+import (
+	. "revisions-checker/common"
+)
+
+// EventKind identifies the kind of notification a change produces.
+type EventKind string
+
+const (
+	// EventNewActiveRevision: a revision started serving traffic.
+	EventNewActiveRevision EventKind = "new-active"
+	// EventRollback: a revision older than the previously serving ones started serving again.
+	EventRollback EventKind = "rollback"
+	// EventRevisionDeactivated: a revision stopped serving traffic, e.g. after a traffic shift to 0%.
+	EventRevisionDeactivated EventKind = "deactivated"
+	// EventTrafficShifted: the traffic split between already serving revisions changed, e.g. a canary going from 10% to 50%.
+	EventTrafficShifted EventKind = "traffic-shifted"
+	// EventActiveRevisionChanged: a serving revision's tracked attributes changed.
+	EventActiveRevisionChanged EventKind = "active-changed"
+	// EventRevisionCreated: a revision was deployed without receiving traffic.
+	EventRevisionCreated EventKind = "created"
+	// EventRevisionDeleted: one of the most recent revisions disappeared.
+	EventRevisionDeleted EventKind = "deleted"
+	// EventRevisionFailed: a revision was deployed but never became ready.
+	EventRevisionFailed EventKind = "failed"
+)
+
+// Event is a single notification-worthy change of a service.
+type Event struct {
+	Kind     EventKind
+	Revision Revision
+	// Previous is the earlier state of Revision for EventActiveRevisionChanged, the revision
+	// rolled back from for EventRollback and the previously serving revision for EventNewActiveRevision.
+	Previous *Revision
+	// TrafficBefore and TrafficAfter are the active revisions before and after an EventTrafficShifted.
+	TrafficBefore []Revision
+	TrafficAfter  []Revision
+	// ContainerChanges lists the container images that differ from the previously serving
+	// revision, or from Previous when it is set.
+	ContainerChanges []ContainerChange
+	// SpecChanges is the configuration diff against Previous, filled in once full revision specs are fetched.
+	SpecChanges []SettingChange
+}
+
+// Events turns a ServiceDiff into the notifications it warrants, active-list changes first.
+func (d ServiceDiff) Events() []Event {
+	var events []Event
+
+	rolledBackFrom := map[string]bool{}
+	newest := d.newestPreviousActive()
+	for _, r := range d.Active.Added {
+		var changes []ContainerChange
+		if newest != nil {
+			changes = Containers(*newest, r)
+		}
+		if from := d.rollbackFrom(r); from != nil {
+			events = append(events, Event{Kind: EventRollback, Revision: r, Previous: from, ContainerChanges: changes})
+			rolledBackFrom[from.Name] = true
+			continue
+		}
+		events = append(events, Event{Kind: EventNewActiveRevision, Revision: r, Previous: newest, ContainerChanges: changes})
+	}
+	for _, r := range d.Active.Removed {
+		// The rollback event already says this revision stopped serving
+		if !rolledBackFrom[r.Name] {
+			events = append(events, Event{Kind: EventRevisionDeactivated, Revision: r})
+		}
+	}
+	trafficShifted := false
+	for _, c := range d.Active.Changed {
+		if !sameTraffic(c.Previous, c.Current) {
+			trafficShifted = true
+		}
+		if !sameDeployment(c.Previous, c.Current) {
+			previous := c.Previous
+			events = append(events, Event{Kind: EventActiveRevisionChanged, Revision: c.Current, Previous: &previous,
+				ContainerChanges: Containers(c.Previous, c.Current)})
+		}
+	}
+	if trafficShifted {
+		events = append(events, Event{Kind: EventTrafficShifted, TrafficBefore: d.PreviousActive, TrafficAfter: d.CurrentActive})
+	}
+
+	active := byName(append(append([]Revision{}, d.Active.Added...), d.Active.Removed...))
+	for _, r := range d.Recent.Added {
+		// Revisions that went live straight away are already reported as new active revisions
+		if _, ok := active[r.Name]; !ok {
+			kind := EventRevisionCreated
+			if r.Failure != "" {
+				kind = EventRevisionFailed
+			}
+			events = append(events, Event{Kind: kind, Revision: r})
+		}
+	}
+	for _, c := range d.Recent.Changed {
+		// A revision reported as created while still deploying may fail later
+		if c.Previous.Failure == "" && c.Current.Failure != "" {
+			events = append(events, Event{Kind: EventRevisionFailed, Revision: c.Current})
+		}
+	}
+	for _, r := range d.Recent.Removed {
+		// A revision pushed out by a newer one merely aged out of the list
+		if !d.agedOut(r) {
+			events = append(events, Event{Kind: EventRevisionDeleted, Revision: r})
+		}
+	}
+
+	return events
+}
+
+func (d ServiceDiff) agedOut(removed Revision) bool {
+	for _, r := range d.Recent.Added {
+		if r.CreationTime.After(removed.CreationTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackFrom returns the newest previously active revision when the newly active revision
+// was created before it and the newest one stopped serving, meaning traffic went back to an older revision.
+// An older revision merely taking a share of the traffic next to the newest one is not a rollback.
+func (d ServiceDiff) rollbackFrom(added Revision) *Revision {
+	newest := d.newestPreviousActive()
+	if newest == nil || !added.CreationTime.Before(newest.CreationTime) {
+		return nil
+	}
+	for _, r := range d.Active.Removed {
+		if r.Name == newest.Name {
+			return newest
+		}
+	}
+
+	return nil
+}
+
+func (d ServiceDiff) newestPreviousActive() *Revision {
+	var newest *Revision
+	for i, r := range d.PreviousActive {
+		if newest == nil || r.CreationTime.After(newest.CreationTime) {
+			newest = &d.PreviousActive[i]
+		}
+	}
+	if newest == nil {
+		return nil
+	}
+
+	copied := *newest
+	return &copied
+}
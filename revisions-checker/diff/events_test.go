@@ -0,0 +1,84 @@
+package diff
+
+import (
+	. "revisions-checker/common"
+	"testing"
+	"time"
+)
+
+func TestRollbackClassification(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1 := Revision{Name: "api-00001", CreationTime: base, Image: "api:1"}
+	v2 := Revision{Name: "api-00002", CreationTime: base.Add(time.Hour), Image: "api:2"}
+	v3 := Revision{Name: "api-00003", CreationTime: base.Add(2 * time.Hour), Image: "api:3"}
+	serving := func(r Revision, percent int64) Revision {
+		r.TrafficPercent = percent
+		return r
+	}
+
+	tests := []struct {
+		name              string
+		previous, current []Revision
+		want              []EventKind
+		// previous is the revision Previous of the first event points to
+		previousOfFirst string
+	}{
+		{
+			name:            "new revision takes all the traffic",
+			previous:        []Revision{serving(v1, 100)},
+			current:         []Revision{serving(v2, 100)},
+			want:            []EventKind{EventNewActiveRevision, EventRevisionDeactivated},
+			previousOfFirst: "api-00001",
+		},
+		{
+			name:            "traffic goes back to an older revision",
+			previous:        []Revision{serving(v2, 100)},
+			current:         []Revision{serving(v1, 100)},
+			want:            []EventKind{EventRollback},
+			previousOfFirst: "api-00002",
+		},
+		{
+			name:            "older revision takes a share next to the newest",
+			previous:        []Revision{serving(v2, 100)},
+			current:         []Revision{serving(v1, 10), serving(v2, 90)},
+			want:            []EventKind{EventNewActiveRevision, EventTrafficShifted},
+			previousOfFirst: "api-00002",
+		},
+		{
+			name:            "newest stops serving while an older one keeps some",
+			previous:        []Revision{serving(v1, 50), serving(v3, 50)},
+			current:         []Revision{serving(v1, 50), serving(v2, 50)},
+			want:            []EventKind{EventRollback},
+			previousOfFirst: "api-00003",
+		},
+		{
+			name:     "older revision was already serving",
+			previous: []Revision{serving(v1, 50), serving(v2, 50)},
+			current:  []Revision{serving(v1, 100)},
+			want:     []EventKind{EventRevisionDeactivated, EventTrafficShifted},
+		},
+	}
+
+	for _, test := range tests {
+		events := Compare(test.previous, test.current, nil, nil).Events()
+
+		var kinds []EventKind
+		for _, event := range events {
+			kinds = append(kinds, event.Kind)
+		}
+		if len(kinds) != len(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, kinds, test.want)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != test.want[i] {
+				t.Errorf("%s: got %v, want %v", test.name, kinds, test.want)
+				break
+			}
+		}
+
+		if test.previousOfFirst != "" && (events[0].Previous == nil || events[0].Previous.Name != test.previousOfFirst) {
+			t.Errorf("%s: Previous of %s = %v, want %s", test.name, events[0].Kind, events[0].Previous, test.previousOfFirst)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package diff
+
+import (
+	. "revisions-checker/common"
+	"sort"
+	"strings"
+)
+
+// SettingChange is one configuration setting that differs between two revisions.
+// Previous is empty for an added setting, Current for a removed one.
+type SettingChange struct {
+	Key      string
+	Previous string
+	Current  string
+}
+
+// Masked reports whether the setting is an env var whose values must not be shown.
+func (c SettingChange) Masked() bool {
+	return strings.HasPrefix(c.Key, "env/")
+}
+
+// Redacted replaces masked values with "(changed)", keeping the side of an added or removed setting empty.
+func (c SettingChange) Redacted() SettingChange {
+	if !c.Masked() {
+		return c
+	}
+	for _, value := range []*string{&c.Previous, &c.Current} {
+		if *value != "" {
+			*value = "(changed)"
+		}
+	}
+	return c
+}
+
+// Specs compares the configuration of two revisions, sorted by setting key.
+// Masked changes are redacted, so env var digests never reach notifications or saved messages.
+func Specs(previous, current RevisionSpec) []SettingChange {
+	var changes []SettingChange
+	for key, value := range current.Settings {
+		if previous.Settings[key] != value {
+			changes = append(changes, SettingChange{Key: key, Previous: previous.Settings[key], Current: value}.Redacted())
+		}
+	}
+	for key, value := range previous.Settings {
+		if _, ok := current.Settings[key]; !ok {
+			changes = append(changes, SettingChange{Key: key, Previous: value}.Redacted())
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// SettingGroups summarises changes by the first segment of their keys, e.g. "env" or "limits".
+func SettingGroups(changes []SettingChange) []string {
+	var groups []string
+	seen := map[string]bool{}
+	for _, change := range changes {
+		group, _, _ := strings.Cut(change.Key, "/")
+		if !seen[group] {
+			seen[group] = true
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,27 @@
+package diff
+
+import (
+	. "revisions-checker/common"
+	"testing"
+)
+
+func TestSpecsRedactsEnvValues(t *testing.T) {
+	previous := RevisionSpec{Settings: map[string]string{"env/app/TOKEN": "hmac:1a2b", "env/app/REMOVED": "hmac:3c4d", "timeout": "300s"}}
+	current := RevisionSpec{Settings: map[string]string{"env/app/TOKEN": "hmac:5e6f", "env/app/ADDED": "hmac:7a8b", "timeout": "60s"}}
+
+	want := []SettingChange{
+		{Key: "env/app/ADDED", Current: "(changed)"},
+		{Key: "env/app/REMOVED", Previous: "(changed)"},
+		{Key: "env/app/TOKEN", Previous: "(changed)", Current: "(changed)"},
+		{Key: "timeout", Previous: "300s", Current: "60s"},
+	}
+	changes := Specs(previous, current)
+	if len(changes) != len(want) {
+		t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+	}
+	for i, change := range changes {
+		if change != want[i] {
+			t.Errorf("changes[%d] = %+v, want %+v", i, change, want[i])
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// Embed limits, see https://discord.com/developers/docs/resources/message#embed-object-embed-limits
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 4096
+	maxFieldValueLength  = 1024
+	maxFields            = 25
+)
+
+// Notifier posts revision events as embeds to a Discord channel webhook.
+// Templates, when set, replace the built-in wording below the revision fields.
+type Notifier struct {
+	WebhookURL string
+	Templates  *templates.Set
+}
+
+// Message is a webhook message, see https://discord.com/developers/docs/resources/webhook#execute-webhook.
+type Message struct {
+	Content         string          `json:"content,omitempty"`
+	Embeds          []Embed         `json:"embeds"`
+	AllowedMentions AllowedMentions `json:"allowed_mentions"`
+}
+
+type Embed struct {
+	Title       string  `json:"title"`
+	URL         string  `json:"url,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Color       int     `json:"color"`
+	Fields      []Field `json:"fields,omitempty"`
+	Timestamp   string  `json:"timestamp,omitempty"`
+}
+
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+// AllowedMentions keeps names in templates or images from pinging anyone.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	text, ok := n.Templates.Render(event)
+	details := notify.Details(event)
+	if ok {
+		details = []notify.Paragraph{{Text: text}}
+	}
+
+	return post(ctx, n.WebhookURL, NewMessage(event, details))
+}
+
+// NewMessage lays out the title, summary, details and revision facts of an event as a single embed
+// linking to the revision, or the service, in the Cloud Console.
+func NewMessage(event notify.RevisionEvent, details []notify.Paragraph) Message {
+	// Paragraphs that do not fit are left out rather than cut, which could leave a code block open
+	description := truncate(Escape(notify.Summary(event)), maxDescriptionLength)
+	for _, paragraph := range details {
+		text := Escape(paragraph.Text)
+		if paragraph.Preformatted {
+			text = "```\n" + strings.ReplaceAll(strings.TrimRight(paragraph.Text, "\n"), "```", "'''") + "\n```"
+		}
+		if utf8.RuneCountInString(description+"\n\n"+text) > maxDescriptionLength {
+			if utf8.RuneCountInString(description+"\n\n…") <= maxDescriptionLength {
+				description += "\n\n…"
+			}
+			break
+		}
+		description += "\n\n" + text
+	}
+
+	var fields []Field
+	for _, fact := range notify.Facts(event) {
+		if len(fields) == maxFields {
+			break
+		}
+		value := fact.Value
+		if value == "" {
+			value = "-"
+		}
+		fields = append(fields, Field{Name: fact.Title, Value: truncate(Escape(value), maxFieldValueLength), Inline: fact.Title != "Image"})
+	}
+
+	embed := Embed{
+		Title:       truncate(notify.Title(event), maxTitleLength),
+		Description: description,
+		Color:       notify.Color(event),
+		Fields:      fields,
+	}
+	if event.Service.ProjectID != "" {
+		embed.URL = notify.ServiceConsoleURL(event.Service)
+		if event.Revision.Name != "" {
+			embed.URL = notify.RevisionConsoleURL(event.Service, event.Revision.Name)
+		}
+	}
+	if !event.Time.IsZero() {
+		embed.Timestamp = event.Time.UTC().Format(time.RFC3339)
+	}
+
+	return Message{Embeds: []Embed{embed}, AllowedMentions: AllowedMentions{Parse: []string{}}}
+}
+
+// Escape backslash-escapes the characters Discord markdown would otherwise interpret.
+func Escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\*_~`|>#[]()-", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
+
+// apiError is the body of failed requests; throttled requests also tell when to retry.
+type apiError struct {
+	Message    string  `json:"message"`
+	Code       int     `json:"code"`
+	RetryAfter float64 `json:"retry_after"`
+}
+
+// post sends msg, waiting for Discord to confirm the message was created so that
+// errors, e.g. an embed over the size limits, are reported instead of silently dropped.
+func post(ctx context.Context, webhookURL string, msg Message) error {
+	target, err := url.Parse(webhookURL)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	query := target.Query()
+	query.Set("wait", "true")
+	target.RawQuery = query.Encode()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var apiErr apiError
+	if json.Unmarshal(answer, &apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(answer))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("throttled by Discord (retry after %gs): %s", apiErr.RetryAfter, apiErr.Message)
+	}
+
+	return fmt.Errorf("Discord returned %s: %s", resp.Status, apiErr.Message)
+}
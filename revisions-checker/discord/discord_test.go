@@ -0,0 +1,159 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestMessageGolden compares the message of every event kind with testdata/<kind>.golden.
+// Run go test ./discord -update after an intended change of the layout and review the diff.
+func TestMessageGolden(t *testing.T) {
+	for _, kind := range templates.Kinds {
+		if kind == templates.Default {
+			continue
+		}
+
+		event := templates.SampleEvent(kind)
+		got, err := json.MarshalIndent(NewMessage(event, notify.Details(event)), "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkGolden(t, kind+".golden", append(got, '\n'))
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"api-00002-abc":         `api\-00002\-abc`,
+		"*bold* _it_ ~strike~":  `\*bold\* \_it\_ \~strike\~`,
+		"`code` > quote | pipe": "\\`code\\` \\> quote \\| pipe",
+		"[link](x) # heading":   `\[link\]\(x\) \# heading`,
+		`C:\path`:               `C:\\path`,
+		"plain text, 100%":      "plain text, 100%",
+	}
+
+	for text, want := range tests {
+		if got := Escape(text); got != want {
+			t.Errorf("Escape(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestEmbedLimits(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventRevisionFailed))
+	event.Revision.Image = strings.Repeat("i", 2000)
+	details := []notify.Paragraph{
+		{Text: "The revision never became ready:"},
+		{Text: "```injected``` " + strings.Repeat("log line\n", 300), Preformatted: true},
+		{Text: strings.Repeat("x", maxDescriptionLength), Preformatted: true},
+		{Text: "Never shown."},
+	}
+
+	embed := NewMessage(event, details).Embeds[0]
+	if n := utf8.RuneCountInString(embed.Description); n > maxDescriptionLength {
+		t.Errorf("description of %d characters, over the limit of %d", n, maxDescriptionLength)
+	}
+	if strings.Count(embed.Description, "```")%2 != 0 {
+		t.Errorf("the description leaves a code block open:\n%s", embed.Description)
+	}
+	if strings.Contains(embed.Description, "```injected```") || !strings.Contains(embed.Description, "'''injected'''") {
+		t.Error("fences inside preformatted paragraphs must be neutralised")
+	}
+	if !strings.HasSuffix(embed.Description, "log line\n```\n\n…") || strings.Contains(embed.Description, "Never shown") {
+		t.Errorf("the paragraphs that do not fit must be left out and marked, got the end %q", embed.Description[len(embed.Description)-40:])
+	}
+	for _, field := range embed.Fields {
+		if n := utf8.RuneCountInString(field.Value); n > maxFieldValueLength {
+			t.Errorf("field %s of %d characters, over the limit of %d", field.Name, n, maxFieldValueLength)
+		}
+	}
+
+	event.Kind = diff.EventKind(strings.Repeat("k", 300))
+	if n := utf8.RuneCountInString(NewMessage(event, nil).Embeds[0].Title); n > maxTitleLength {
+		t.Errorf("title of %d characters, over the limit of %d", n, maxTitleLength)
+	}
+}
+
+func TestPostResponses(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	tests := []struct {
+		name    string
+		status  int
+		answer  string
+		wantErr string
+	}{
+		{name: "created", status: http.StatusOK, answer: `{"id":"1"}`},
+		{name: "invalid embed", status: http.StatusBadRequest, answer: `{"message":"Invalid Form Body","code":50035}`, wantErr: "Discord returned 400 Bad Request: Invalid Form Body"},
+		{name: "throttled", status: http.StatusTooManyRequests, answer: `{"message":"You are being rate limited.","retry_after":1.5}`, wantErr: "throttled by Discord (retry after 1.5s): You are being rate limited."},
+		{name: "plain text error", status: http.StatusBadGateway, answer: "upstream unavailable", wantErr: "Discord returned 502 Bad Gateway: upstream unavailable"},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("wait") != "true" {
+				t.Errorf("%s: posted without waiting for the message to be created", test.name)
+			}
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.answer))
+		}))
+
+		err := (Notifier{WebhookURL: server.URL + "/api/webhooks/1/secret-token"}).Notify(context.Background(), event)
+		server.Close()
+
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && (err == nil || err.Error() != test.wantErr):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestErrorsKeepTheTokenOut(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for _, webhookURL := range []string{"https://discord com/api/webhooks/1/secret-token", server.URL + "/api/webhooks/1/secret-token"} {
+		err := (Notifier{WebhookURL: webhookURL}).Notify(context.Background(), templates.SampleEvent(string(diff.EventNewActiveRevision)))
+		if err == nil || strings.Contains(err.Error(), "secret-token") {
+			t.Errorf("posting to %s: got error %v", webhookURL, err)
+		}
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
@@ -0,0 +1,218 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"text/template"
+	"time"
+
+	"revisions-checker/config"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// Notifier emails revision events over SMTP as multipart messages with a plain text and an HTML body.
+// Templates, when set, replace the built-in wording of both bodies.
+type Notifier struct {
+	Address  string
+	Username string
+	Password string
+	From     string
+	// RequireTLS refuses to send over a session that could not be upgraded with STARTTLS
+	RequireTLS bool
+	// Recipients maps event kinds, or templates.Default for every other kind, to addresses
+	Recipients map[string][]string
+	Templates  *templates.Set
+	// Configuration is exposed to the built-in bodies like to templates, redacted
+	Configuration config.Configuration
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	recipients := n.recipients(event)
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	message, err := n.message(event, recipients)
+	if err != nil {
+		return err
+	}
+
+	return n.send(ctx, recipients, message)
+}
+
+func (n Notifier) recipients(event notify.RevisionEvent) []string {
+	if recipients, ok := n.Recipients[string(event.Kind)]; ok {
+		return recipients
+	}
+	return n.Recipients[templates.Default]
+}
+
+// message renders the full RFC 5322 message, headers included.
+func (n Notifier) message(event notify.RevisionEvent, recipients []string) ([]byte, error) {
+	data := templates.NewData(event, n.Configuration.Redacted())
+	if text, ok := n.Templates.Render(event); ok {
+		data.Details = []notify.Paragraph{{Text: text}}
+	}
+
+	var plain, html bytes.Buffer
+	if err := plainBody.Execute(&plain, data); err != nil {
+		return nil, err
+	}
+	if err := htmlBody.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	body := multipart.NewWriter(&b)
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[Cloud Run] "+data.Summary))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", body.Boundary())
+
+	// Clients show the last alternative they support, so the HTML body goes last
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{{"text/plain; charset=utf-8", plain.Bytes()}, {"text/html; charset=utf-8", html.Bytes()}} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	message.Write(b.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// send delivers message over one SMTP session, upgrading it with STARTTLS when the server offers it
+// and failing when it does not and RequireTLS is set.
+func (n Notifier) send(ctx context.Context, recipients []string, message []byte) error {
+	host, _, err := net.SplitHostPort(n.Address)
+	if err != nil {
+		return err
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.Address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS: %w", err)
+		}
+	} else if n.RequireTLS {
+		return fmt.Errorf("%s does not offer STARTTLS and require_tls is set", n.Address)
+	}
+	if n.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection to a remote host
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return fmt.Errorf("authenticating: %w", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(n.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		to, err := mail.ParseAddress(recipient)
+		if err != nil {
+			return err
+		}
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("recipient %s: %w", to.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+var plainBody = template.Must(template.New("plain").Funcs(templates.Funcs).Funcs(template.FuncMap{"trim": strings.TrimSpace}).Parse(`{{.Title}}
+{{.Summary}}
+{{range .Facts}}
+{{.Title}}: {{.Value}}{{end}}
+{{range .Details}}
+{{trim .Text}}
+{{end}}
+{{- if and .Revision.Name .Service.ProjectID}}
+Revision in Cloud Console: {{revisionURL .Service .Revision.Name}}{{end}}
+{{- if .Service.URL}}
+Service URL: {{.Service.URL}}{{end}}
+`))
+
+var htmlBody = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap(templates.Funcs)).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Title}}</h2>
+<p>{{.Summary}}</p>
+<table cellpadding="4">
+{{- range .Facts}}
+<tr><th align="left">{{.Title}}</th><td><code>{{.Value}}</code></td></tr>
+{{- end}}
+</table>
+{{- range .Details}}
+{{if .Preformatted}}<pre>{{.Text}}</pre>{{else}}<p style="white-space: pre-line">{{.Text}}</p>{{end}}
+{{- end}}
+<p>
+{{- if and .Revision.Name .Service.ProjectID}}<a href="{{revisionURL .Service .Revision.Name}}">Open revision in console</a>{{end}}
+{{- if .Service.URL}} &middot; <a href="{{.Service.URL}}">Open service URL</a>{{end}}
+</p>
+</body>
+</html>
+`))
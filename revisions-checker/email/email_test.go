@@ -0,0 +1,176 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"revisions-checker/templates"
+)
+
+// session is what the stub SMTP server received.
+type session struct {
+	from       string
+	recipients []string
+	data       string
+}
+
+// serveSMTP accepts a single plaintext SMTP session, without STARTTLS, and sends what it received to done.
+func serveSMTP(t *testing.T, listener net.Listener, done chan<- session) {
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Error(err)
+		close(done)
+		return
+	}
+	defer conn.Close()
+
+	var s session
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			done <- s
+			return
+		}
+		command := strings.TrimSpace(line)
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250-stub")
+			reply("250 8BITMIME")
+		case "MAIL":
+			s.from = pathOf(command)
+			reply("250 OK")
+		case "RCPT":
+			s.recipients = append(s.recipients, pathOf(command))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.data = data.String()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			done <- s
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// pathOf returns the address between angle brackets of a MAIL or RCPT command.
+func pathOf(command string) string {
+	_, path, _ := strings.Cut(command, "<")
+	path, _, _ = strings.Cut(path, ">")
+	return path
+}
+
+func startSMTP(t *testing.T) (string, <-chan session) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	done := make(chan session, 1)
+	go serveSMTP(t, listener, done)
+
+	return listener.Addr().String(), done
+}
+
+func TestNotifySendsMultipartAlternative(t *testing.T) {
+	address, done := startSMTP(t)
+	n := Notifier{
+		Address: address,
+		From:    "Revisions Checker <checker@example.com>",
+		Recipients: map[string][]string{
+			templates.Default: {"ops@example.com"},
+			"new-active":      {"Dev Team <dev@example.com>", "qa@example.com"},
+		},
+	}
+
+	if err := n.Notify(context.Background(), templates.SampleEvent("new-active")); err != nil {
+		t.Fatal(err)
+	}
+	s := <-done
+
+	if s.from != "checker@example.com" {
+		t.Errorf("MAIL FROM %q", s.from)
+	}
+	if got := strings.Join(s.recipients, ","); got != "dev@example.com,qa@example.com" {
+		t.Errorf("RCPT TO %s, want the recipients of new-active only", got)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to := msg.Header.Get("To"); to != "Dev Team <dev@example.com>, qa@example.com" {
+		t.Errorf("To: %s", to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || !strings.HasPrefix(subject, "[Cloud Run] New active revision sample-00002-def") {
+		t.Errorf("Subject: %q (%v)", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type %q (%v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, part.Header.Get("Content-Type"))
+		if encoding := part.Header.Get("Content-Transfer-Encoding"); encoding != "quoted-printable" {
+			t.Errorf("%s part encoded as %q", part.Header.Get("Content-Type"), encoding)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(body), "sample-00002-def") {
+			t.Errorf("%s part does not mention the revision:\n%s", part.Header.Get("Content-Type"), body)
+		}
+	}
+	if got := strings.Join(types, " | "); got != "text/plain; charset=utf-8 | text/html; charset=utf-8" {
+		t.Errorf("parts %s, want the plain text then the HTML body", got)
+	}
+}
+
+func TestNotifyRequiresTLS(t *testing.T) {
+	address, done := startSMTP(t)
+	n := Notifier{Address: address, From: "checker@example.com", RequireTLS: true,
+		Recipients: map[string][]string{templates.Default: {"ops@example.com"}}}
+
+	err := n.Notify(context.Background(), templates.SampleEvent("new-active"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("got error %v, want a refusal to send without STARTTLS", err)
+	}
+	if s := <-done; s.from != "" || s.data != "" {
+		t.Errorf("the message was sent in the clear: %+v", s)
+	}
+}
@@ -3,12 +3,18 @@ package firestore
 import (
 	"cloud.google.com/go/firestore"
 	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"log"
 	. "revisions-checker/common"
+	"strconv"
 )
 
 type RevisionsDocument struct {
 	Revisions []Revision `json:"revisions"`
+	// Shards counts the documents the snapshot is split into; documents written before sharding have none
+	Shards int `firestore:"shards" json:"shards"`
 }
 
 func FetchFirestoreDocument(ctx context.Context, projectID, collectionName, documentID string) ([]Revision, error) {
@@ -16,8 +22,7 @@ func FetchFirestoreDocument(ctx context.Context, projectID, collectionName, docu
 	//client, err := firestore.NewClient(ctx, projectID, option.WithCredentialsFile("path/to/your/service-account-file.json"))
 	client, err := firestore.NewClient(context.Background(), projectID)
 	if err != nil {
-		log.Fatalf("Failed to create Firestore client: %v", err)
-		return nil, err
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
 	}
 	defer client.Close()
 
@@ -26,6 +31,9 @@ func FetchFirestoreDocument(ctx context.Context, projectID, collectionName, docu
 
 	// Get the document
 	docSnapshot, err := docRef.Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("%w: document %s/%s", ErrSnapshotNotFound, collectionName, documentID)
+	}
 	if err != nil {
 		log.Printf("Failed to get document: %v", err)
 		return nil, err
@@ -45,5 +53,19 @@ func FetchFirestoreDocument(ctx context.Context, projectID, collectionName, docu
 		return nil, err
 	}
 
-	return revisionsDoc.Revisions, nil
+	revisions := revisionsDoc.Revisions
+	for i := 1; i < revisionsDoc.Shards; i++ {
+		shardSnapshot, err := docRef.Collection("shards").Doc(strconv.Itoa(i)).Get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get shard %d of document %s/%s: %w", i, collectionName, documentID, err)
+		}
+
+		var shard RevisionsDocument
+		if err := shardSnapshot.DataTo(&shard); err != nil {
+			return nil, fmt.Errorf("failed to decode shard %d of document %s/%s: %w", i, collectionName, documentID, err)
+		}
+		revisions = append(revisions, shard.Revisions...)
+	}
+
+	return revisions, nil
 }
@@ -0,0 +1,55 @@
+package firestore
+
+import (
+	fstore "cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	. "revisions-checker/common"
+)
+
+// AppendHistoryToFirestore stores every entry as its own document of the "history" subcollection
+// of documentName, so the history of a service can grow without hitting the document size limit.
+func AppendHistoryToFirestore(ctx context.Context, projectID, collectionName, documentName string, entries []HistoryEntry) error {
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer firestoreClient.Close()
+
+	history := firestoreClient.Collection(collectionName).Doc(documentName).Collection("history")
+	batch := firestoreClient.Batch()
+	for _, entry := range entries {
+		batch.Create(history.NewDoc(), entry)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to append history to document { %s }: %w", documentName, err)
+	}
+
+	return nil
+}
+
+// FetchHistoryFromFirestore returns the history of documentName, oldest entry first.
+func FetchHistoryFromFirestore(ctx context.Context, projectID, collectionName, documentName string) ([]HistoryEntry, error) {
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer firestoreClient.Close()
+
+	docs, err := firestoreClient.Collection(collectionName).Doc(documentName).Collection("history").
+		OrderBy("Time", fstore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history of document { %s }: %w", documentName, err)
+	}
+
+	entries := make([]HistoryEntry, 0, len(docs))
+	for _, doc := range docs {
+		var entry HistoryEntry
+		if err := doc.DataTo(&entry); err != nil {
+			return nil, fmt.Errorf("failed to decode history entry %s: %w", doc.Ref.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,54 @@
+package firestore
+
+import (
+	fstore "cloud.google.com/go/firestore"
+	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "revisions-checker/common"
+)
+
+// MessagesDocument holds the messages notifiers posted about the revisions of a service, keyed by revision name.
+type MessagesDocument struct {
+	Messages map[string]PostedMessage `firestore:"messages" json:"messages"`
+}
+
+// FetchMessagesFromFirestore returns the messages saved in documentName, none when it does not exist.
+func FetchMessagesFromFirestore(ctx context.Context, projectID, collectionName, documentName string) (map[string]PostedMessage, error) {
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer firestoreClient.Close()
+
+	doc, err := firestoreClient.Collection(collectionName).Doc(documentName).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document { %s }: %w", documentName, err)
+	}
+
+	var messages MessagesDocument
+	if err := doc.DataTo(&messages); err != nil {
+		return nil, fmt.Errorf("failed to decode document { %s }: %w", documentName, err)
+	}
+
+	return messages.Messages, nil
+}
+
+// SubmitMessagesToFirestore replaces the messages saved in documentName.
+func SubmitMessagesToFirestore(ctx context.Context, projectID, collectionName, documentName string, messages map[string]PostedMessage) error {
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
+	if err != nil {
+		return fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer firestoreClient.Close()
+
+	if _, err := firestoreClient.Collection(collectionName).Doc(documentName).Set(ctx, MessagesDocument{Messages: messages}); err != nil {
+		return fmt.Errorf("failed to write document { %s }: %w", documentName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package firestore
+
+import (
+	"context"
+	. "revisions-checker/common"
+)
+
+// Store keeps revision snapshots as documents of a Firestore collection, one document per key.
+type Store struct {
+	ProjectID  string
+	Collection string
+}
+
+func (s Store) GetSnapshot(ctx context.Context, key string) ([]Revision, error) {
+	return FetchFirestoreDocument(ctx, s.ProjectID, s.Collection, key)
+}
+
+func (s Store) PutSnapshot(ctx context.Context, key string, revisions []Revision) error {
+	return SubmitRevisionsToFirestore(ctx, s.ProjectID, s.Collection, key, revisions)
+}
+
+func (s Store) DeleteSnapshot(ctx context.Context, key string) error {
+	return DeleteFirestoreDocument(ctx, s.ProjectID, s.Collection, key)
+}
+
+func (s Store) AppendHistory(ctx context.Context, key string, entries []HistoryEntry) error {
+	return AppendHistoryToFirestore(ctx, s.ProjectID, s.Collection, key, entries)
+}
+
+func (s Store) GetHistory(ctx context.Context, key string) ([]HistoryEntry, error) {
+	return FetchHistoryFromFirestore(ctx, s.ProjectID, s.Collection, key)
+}
+
+func (s Store) GetMessages(ctx context.Context, key string) (map[string]PostedMessage, error) {
+	return FetchMessagesFromFirestore(ctx, s.ProjectID, s.Collection, key)
+}
+
+func (s Store) PutMessages(ctx context.Context, key string, messages map[string]PostedMessage) error {
+	return SubmitMessagesToFirestore(ctx, s.ProjectID, s.Collection, key, messages)
+}
+
+func (s Store) Close() error {
+	return nil
+}
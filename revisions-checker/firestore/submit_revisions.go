@@ -6,24 +6,76 @@ import (
 	"fmt"
 	"log"
 	. "revisions-checker/common"
-	. "revisions-checker/config"
+	"strconv"
 )
 
+// revisionsPerShard keeps snapshot documents well below the Firestore document size limit.
+const revisionsPerShard = 200
+
 // func submitToFirestore(ctx context.Context, projectID string, serviceName string, activeRevisions, threeMostRecentRevisions []*run.GoogleCloudRunV2Revision) {
-func SubmitRevisionsToFirestore(ctx context.Context, config Configuration, documentName string, revisions []Revision) {
+func SubmitRevisionsToFirestore(ctx context.Context, projectID, collectionName, documentName string, revisions []Revision) error {
 	// Firestore setup
-	firestoreClient, err := fstore.NewClient(context.Background(), config.ProjectID)
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
 	if err != nil {
-		log.Fatalf("Failed to create Firestore client: %v", err)
+		return fmt.Errorf("failed to create Firestore client: %w", err)
 	}
 	defer firestoreClient.Close()
 
-	_, err = firestoreClient.Collection("revisions").Doc(documentName).Set(ctx, map[string]interface{}{
-		"revisions": revisions,
+	// Large snapshots are split so no document exceeds Firestore's 1 MiB limit: the document itself
+	// holds the first shard and the number of shards, the others go to its "shards" subcollection
+	docRef := firestoreClient.Collection(collectionName).Doc(documentName)
+	shards := shardRevisions(revisions)
+	batch := firestoreClient.Batch()
+	batch.Set(docRef, map[string]interface{}{
+		"revisions": shards[0],
+		"shards":    len(shards),
 	})
+	for i := 1; i < len(shards); i++ {
+		batch.Set(docRef.Collection("shards").Doc(strconv.Itoa(i)), map[string]interface{}{
+			"revisions": shards[i],
+		})
+	}
+	_, err = batch.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to write revisions to document { %s }: %w", documentName, err)
+	}
+
+	log.Printf("Revisions successfully written to Firestore")
+
+	return nil
+}
+
+// shardRevisions splits revisions into chunks of revisionsPerShard, always returning at least one chunk.
+func shardRevisions(revisions []Revision) [][]Revision {
+	shards := [][]Revision{revisions[:min(len(revisions), revisionsPerShard)]}
+	for start := revisionsPerShard; start < len(revisions); start += revisionsPerShard {
+		shards = append(shards, revisions[start:min(len(revisions), start+revisionsPerShard)])
+	}
+
+	return shards
+}
+
+// DeleteFirestoreDocument removes a snapshot document along with its shards.
+func DeleteFirestoreDocument(ctx context.Context, projectID, collectionName, documentName string) error {
+	firestoreClient, err := fstore.NewClient(context.Background(), projectID)
 	if err != nil {
-		log.Fatalf("Failed to write revisions to document { %s }: %v", documentName, err)
+		return fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	defer firestoreClient.Close()
+
+	docRef := firestoreClient.Collection(collectionName).Doc(documentName)
+	shards, err := docRef.Collection("shards").DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list the shards of document { %s }: %w", documentName, err)
+	}
+	batch := firestoreClient.Batch()
+	for _, shard := range shards {
+		batch.Delete(shard)
+	}
+	batch.Delete(docRef)
+	if _, err := batch.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to delete document { %s }: %w", documentName, err)
 	}
 
-	fmt.Println("Revisions successfully written to Firestore")
+	return nil
 }
@@ -0,0 +1,159 @@
+package googlechat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// Notifier posts revision events as cardsV2 messages to a Google Chat space webhook.
+// All events of a service posted on the same (UTC) day share a thread.
+// Templates, when set, replace the built-in wording below the revision facts.
+type Notifier struct {
+	WebhookURL string
+	Templates  *templates.Set
+}
+
+// Message is a Google Chat message, see https://developers.google.com/chat/api/reference/rest/v1/spaces.messages.
+type Message struct {
+	Text    string   `json:"text"`
+	CardsV2 []CardV2 `json:"cardsV2"`
+	Thread  *Thread  `json:"thread,omitempty"`
+}
+
+type CardV2 struct {
+	CardID string `json:"cardId"`
+	Card   Card   `json:"card"`
+}
+
+type Card struct {
+	Header   CardHeader `json:"header"`
+	Sections []Section  `json:"sections"`
+}
+
+type CardHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type Section struct {
+	Header string `json:"header,omitempty"`
+	// Widgets hold a single key each, e.g. "decoratedText", "textParagraph" or "buttonList"
+	Widgets []map[string]interface{} `json:"widgets"`
+}
+
+type Thread struct {
+	ThreadKey string `json:"threadKey"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	text, ok := n.Templates.Render(event)
+	details := notify.Details(event)
+	if ok {
+		details = []notify.Paragraph{{Text: text}}
+	}
+
+	return post(ctx, n.WebhookURL, NewMessage(event, details))
+}
+
+// NewMessage lays out the title, the revision facts, details and console links of an event as a card.
+func NewMessage(event notify.RevisionEvent, details []notify.Paragraph) Message {
+	var facts []map[string]interface{}
+	for _, fact := range notify.Facts(event) {
+		facts = append(facts, map[string]interface{}{
+			"decoratedText": map[string]interface{}{"topLabel": fact.Title, "text": html.EscapeString(fact.Value)},
+		})
+	}
+	sections := []Section{{Widgets: facts}}
+
+	if len(details) > 0 {
+		var paragraphs []map[string]interface{}
+		for _, paragraph := range details {
+			text := strings.ReplaceAll(html.EscapeString(strings.TrimRight(paragraph.Text, "\n")), "\n", "<br>")
+			paragraphs = append(paragraphs, map[string]interface{}{"textParagraph": map[string]interface{}{"text": text}})
+		}
+		sections = append(sections, Section{Header: "Details", Widgets: paragraphs})
+	}
+
+	var buttons []map[string]interface{}
+	if event.Revision.Name != "" && event.Service.ProjectID != "" {
+		buttons = append(buttons, openLink("Open revision in console", notify.RevisionConsoleURL(event.Service, event.Revision.Name)))
+	}
+	if event.Service.URL != "" {
+		buttons = append(buttons, openLink("Open service URL", event.Service.URL))
+	}
+	if len(buttons) > 0 {
+		sections = append(sections, Section{Widgets: []map[string]interface{}{{"buttonList": map[string]interface{}{"buttons": buttons}}}})
+	}
+
+	return Message{
+		Text: notify.Summary(event),
+		CardsV2: []CardV2{{
+			CardID: "revision-event",
+			Card: Card{
+				Header:   CardHeader{Title: notify.Title(event), Subtitle: event.Service.Name},
+				Sections: sections,
+			},
+		}},
+		Thread: &Thread{ThreadKey: ThreadKey(event)},
+	}
+}
+
+// ThreadKey groups the events of one service by UTC day.
+func ThreadKey(event notify.RevisionEvent) string {
+	day := event.Time
+	if day.IsZero() {
+		day = time.Now()
+	}
+
+	return fmt.Sprintf("%s/%s", event.Service.Name, day.UTC().Format("2006-01-02"))
+}
+
+func openLink(text, url string) map[string]interface{} {
+	return map[string]interface{}{"text": text, "onClick": map[string]interface{}{"openLink": map[string]interface{}{"url": url}}}
+}
+
+// post sends msg, replying in the thread of its key or starting that thread when it does not exist yet.
+func post(ctx context.Context, webhookURL string, msg Message) error {
+	target, err := url.Parse(webhookURL)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	query := target.Query()
+	query.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	target.RawQuery = query.Encode()
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("Google Chat returned %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+
+	return nil
+}
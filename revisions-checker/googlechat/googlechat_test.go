@@ -0,0 +1,54 @@
+package googlechat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"revisions-checker/diff"
+	"revisions-checker/templates"
+)
+
+const testKey = "key=AIzaSecret&token=secret-token"
+
+func TestNotifyThreadsPerServiceAndDay(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	var got Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("messageReplyOption") != "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD" || r.URL.Query().Get("token") != "secret-token" {
+			t.Errorf("got query %s", r.URL.RawQuery)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	if err := (Notifier{WebhookURL: server.URL + "/v1/spaces/AAA/messages?" + testKey}).Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Thread == nil || got.Thread.ThreadKey != ThreadKey(event) {
+		t.Errorf("posted thread %+v, want %s", got.Thread, ThreadKey(event))
+	}
+}
+
+func TestErrorsKeepTheWebhookURLOut(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for webhookURL, want := range map[string]string{
+		"https://chat googleapis.com/v1/spaces/AAA/messages?" + testKey: "invalid URL",
+		server.URL + "/v1/spaces/AAA/messages?" + testKey:               "connection refused",
+	} {
+		err := (Notifier{WebhookURL: webhookURL}).Notify(context.Background(), event)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("posting to %s: got error %v, want %q", webhookURL, err, want)
+		} else if strings.Contains(err.Error(), "secret") {
+			t.Errorf("the error leaks the webhook URL: %v", err)
+		}
+	}
+}
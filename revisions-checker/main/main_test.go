@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	. "revisions-checker/cloudrun"
+	. "revisions-checker/common"
+	. "revisions-checker/config"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	. "revisions-checker/state"
+	"sync"
+	"testing"
+)
+
+// recorder is a notifier remembering every event it was sent.
+type recorder struct {
+	mu     sync.Mutex
+	events []notify.RevisionEvent
+}
+
+func (r *recorder) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+	return nil
+}
+
+// take returns the events recorded since the last call.
+func (r *recorder) take() []notify.RevisionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func TestExecute(t *testing.T) {
+	ctx := context.Background()
+	config := Defaults()
+	config.ProjectID, config.Region = "p", "us-central1"
+
+	client := NewFakeClient()
+	service := client.AddService("p", "us-central1", "api")
+	checked := Service{Name: service, ProjectID: "p", Region: "us-central1"}
+	store := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	notifications := &recorder{}
+	dispatcher := notify.NewDispatcher(notify.Named{Name: "recorder", Notifier: notifications})
+
+	steps := []struct {
+		name   string
+		change func()
+		want   []diff.EventKind
+		// revision is the revision of the first event, if any
+		revision string
+		// serving is the saved active revisions after the run
+		serving []string
+	}{
+		{
+			name:    "first run",
+			change:  func() { client.Deploy(service, "api:1") },
+			serving: []string{"api-00001"},
+		},
+		{
+			name:     "new active revision",
+			change:   func() { client.Deploy(service, "api:2") },
+			want:     []diff.EventKind{diff.EventNewActiveRevision, diff.EventRevisionDeactivated},
+			revision: "api-00002",
+			serving:  []string{"api-00002"},
+		},
+		{
+			name:     "rollback",
+			change:   func() { client.ShiftTraffic(service, map[string]int64{"api-00001": 100}) },
+			want:     []diff.EventKind{diff.EventRollback},
+			revision: "api-00001",
+			serving:  []string{"api-00001"},
+		},
+		{
+			name:     "canary",
+			change:   func() { client.ShiftTraffic(service, map[string]int64{"api-00001": 90, "api-00002": 10}) },
+			want:     []diff.EventKind{diff.EventNewActiveRevision, diff.EventTrafficShifted},
+			revision: "api-00002",
+			serving:  []string{"api-00001", "api-00002"},
+		},
+		{
+			name:    "traffic shift",
+			change:  func() { client.ShiftTraffic(service, map[string]int64{"api-00001": 50, "api-00002": 50}) },
+			want:    []diff.EventKind{diff.EventTrafficShifted},
+			serving: []string{"api-00001", "api-00002"},
+		},
+		{
+			name:     "failed revision",
+			change:   func() { client.DeployFailing(service, "api:3", "container failed to start") },
+			want:     []diff.EventKind{diff.EventRevisionFailed},
+			revision: "api-00003",
+			serving:  []string{"api-00001", "api-00002"},
+		},
+		{
+			name:    "nothing changed",
+			change:  func() {},
+			serving: []string{"api-00001", "api-00002"},
+		},
+	}
+
+	for _, step := range steps {
+		step.change()
+
+		report := execute(ctx, config, client, store, dispatcher)
+		if report.HasFailures() {
+			report.Log()
+			t.Fatalf("%s: the run failed", step.name)
+		}
+
+		events := notifications.take()
+		var kinds []diff.EventKind
+		for _, event := range events {
+			kinds = append(kinds, event.Kind)
+		}
+		if !equalKinds(kinds, step.want) {
+			t.Errorf("%s: got events %v, want %v", step.name, kinds, step.want)
+		} else if len(events) > 0 && events[0].Revision.Name != step.revision {
+			t.Errorf("%s: got an event about %q, want %q", step.name, events[0].Revision.Name, step.revision)
+		}
+
+		saved, err := store.GetSnapshot(ctx, DocumentName(config.ActiveRevisionsFirebaseDocumentPrefix, checked))
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		var names []string
+		for _, revision := range saved {
+			names = append(names, revision.Name)
+		}
+		if !equalStrings(names, step.serving) {
+			t.Errorf("%s: saved active revisions %v, want %v", step.name, names, step.serving)
+		}
+	}
+
+	history, err := store.GetHistory(ctx, DocumentName(config.HistoryDocumentPrefix, checked))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) == 0 {
+		t.Error("no history was recorded")
+	}
+}
+
+func TestExecuteIsolatesFailingProjects(t *testing.T) {
+	config := Defaults()
+	config.DiscoverRegions = true
+	config.Targets = []Target{{ProjectID: "a"}, {ProjectID: "b"}}
+
+	client := NewFakeClient()
+	client.Deploy(client.AddService("a", "us-central1", "api"), "api:1")
+	client.Deploy(client.AddService("b", "europe-west1", "api"), "api:1")
+	client.FailNext(MethodListLocations, errors.New("permission denied"))
+
+	store := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	report := execute(context.Background(), config, client, store, notify.NewDispatcher())
+
+	if len(report.TargetFailures) != 1 || report.TargetFailures[0].Target != (Target{ProjectID: "a"}) {
+		t.Errorf("target failures = %v, want a failure of project a", report.TargetFailures)
+	}
+	if len(report.Succeeded) != 1 || report.Succeeded[0].Service.ProjectID != "b" {
+		t.Errorf("succeeded = %v, want the service of project b", report.Succeeded)
+	}
+}
+
+func equalKinds(a, b []diff.EventKind) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
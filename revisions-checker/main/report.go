@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"net/http"
+	. "revisions-checker/common"
+	. "revisions-checker/config"
+	"revisions-checker/notify"
+	"sync"
+)
+
+// ServiceResult is the outcome of checking one service.
+type ServiceResult struct {
+	Service Service
+	// Deliveries holds one entry per notification and destination
+	Deliveries []notify.Delivery
+	Err        error
+}
+
+// TargetFailure records a project/region whose services could not even be listed.
+type TargetFailure struct {
+	Target Target
+	Err    error
+}
+
+// RunReport aggregates the results of one execute call. It is safe for concurrent use.
+type RunReport struct {
+	mu             sync.Mutex
+	Succeeded      []ServiceResult
+	Failed         []ServiceResult
+	TargetFailures []TargetFailure
+}
+
+// record keeps the outcome of one notification at every destination.
+func (r *ServiceResult) record(deliveries []notify.Delivery) {
+	r.Deliveries = append(r.Deliveries, deliveries...)
+}
+
+// delivered reports whether any notification of the service reached a destination.
+func (r ServiceResult) delivered() bool {
+	for _, delivery := range r.Deliveries {
+		if delivery.Err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RunReport) addService(result ServiceResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if result.Err != nil {
+		r.Failed = append(r.Failed, result)
+	} else {
+		r.Succeeded = append(r.Succeeded, result)
+	}
+}
+
+func (r *RunReport) addTargetFailure(target Target, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.TargetFailures = append(r.TargetFailures, TargetFailure{Target: target, Err: err})
+}
+
+// DeliveryCount tallies the notifications of one destination.
+type DeliveryCount struct {
+	Notifier     string
+	Sent, Failed int
+}
+
+// NotificationsSent counts the notifications delivered across all services and destinations.
+func (r *RunReport) NotificationsSent() (sent, failed int) {
+	for _, count := range r.Deliveries() {
+		sent += count.Sent
+		failed += count.Failed
+	}
+
+	return sent, failed
+}
+
+// Deliveries counts the notifications per destination, in the order destinations were first used.
+func (r *RunReport) Deliveries() []DeliveryCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var counts []DeliveryCount
+	index := map[string]int{}
+	for _, results := range [][]ServiceResult{r.Succeeded, r.Failed} {
+		for _, result := range results {
+			for _, delivery := range result.Deliveries {
+				i, ok := index[delivery.Notifier]
+				if !ok {
+					i = len(counts)
+					index[delivery.Notifier] = i
+					counts = append(counts, DeliveryCount{Notifier: delivery.Notifier})
+				}
+				if delivery.Err != nil {
+					counts[i].Failed++
+				} else {
+					counts[i].Sent++
+				}
+			}
+		}
+	}
+
+	return counts
+}
+
+// HasFailures reports whether any target or service failed.
+func (r *RunReport) HasFailures() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.Failed) > 0 || len(r.TargetFailures) > 0
+}
+
+// RetryableError returns a non-nil error only when at least one failure is transient,
+// so that a retried Cloud Function invocation has a chance to succeed.
+// Failed notifications are never retried since the state has already moved on, and neither are
+// services whose state could not be saved after notifications went out, which a retry would repeat.
+func (r *RunReport) RetryableError() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	for _, failure := range r.TargetFailures {
+		if isRetryable(failure.Err) {
+			errs = append(errs, fmt.Errorf("%s: %w", failure.Target, failure.Err))
+		}
+	}
+	for _, result := range r.Failed {
+		if isRetryable(result.Err) && !result.delivered() {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Service.Name, result.Err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("run failed with retryable errors: %w", errors.Join(errs...))
+}
+
+// Log prints a summary of the run followed by every failure.
+func (r *RunReport) Log() {
+	sent, failedNotifications := r.NotificationsSent()
+	deliveries := r.Deliveries()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("Run report: %d services succeeded, %d failed, %d targets failed, %d notifications sent, %d notifications failed",
+		len(r.Succeeded), len(r.Failed), len(r.TargetFailures), sent, failedNotifications)
+	for _, count := range deliveries {
+		log.Printf("Notifier %s: %d sent, %d failed", count.Notifier, count.Sent, count.Failed)
+	}
+	for _, failure := range r.TargetFailures {
+		log.Printf("Target %s failed: %v", failure.Target, failure.Err)
+	}
+	for _, result := range r.Failed {
+		log.Printf("Service %s failed: %v", result.Service.Name, result.Err)
+	}
+	for _, results := range [][]ServiceResult{r.Succeeded, r.Failed} {
+		for _, result := range results {
+			for _, delivery := range result.Deliveries {
+				if delivery.Err != nil {
+					log.Printf("Notification for %s failed: %v", result.Service.Name, delivery.Err)
+				}
+			}
+		}
+	}
+}
+
+// isRetryable reports whether err looks transient: timeouts, throttling and server-side errors.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		switch grpcErr.GRPCStatus().Code() {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+			return true
+		}
+	}
+
+	return false
+}
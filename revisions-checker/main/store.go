@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	. "revisions-checker/config"
+	"revisions-checker/firestore"
+	. "revisions-checker/state"
+)
+
+// NewStateStore opens the backend selected by config.StateBackend.
+// Firestore state lives in FirestoreProjectID, which defaults to the first configured project.
+func NewStateStore(config Configuration) (StateStore, error) {
+	switch config.StateBackend {
+	case BackendFirestore, "":
+		projectID := config.FirestoreProjectID
+		if projectID == "" {
+			projectID = config.ConfiguredTargets()[0].ProjectID
+		}
+		return firestore.Store{ProjectID: projectID, Collection: config.StateCollection}, nil
+	case BackendFile:
+		return NewFileStore(config.StatePath), nil
+	case BackendBolt:
+		return NewBoltStore(config.StatePath, config.StateCollection)
+	default:
+		return nil, fmt.Errorf("unknown state backend %q", config.StateBackend)
+	}
+}
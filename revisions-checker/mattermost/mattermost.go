@@ -0,0 +1,146 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// Notifier posts revision events as message attachments to a Mattermost incoming webhook.
+// Templates, when set, replace the built-in wording above the revision fields.
+type Notifier struct {
+	WebhookURL string
+	Templates  *templates.Set
+}
+
+// Message is an incoming webhook payload, see https://developers.mattermost.com/integrate/webhooks/incoming/.
+type Message struct {
+	Text        string       `json:"text,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+// Attachment follows the Slack attachment format Mattermost supports,
+// see https://developers.mattermost.com/integrate/reference/message-attachments/.
+type Attachment struct {
+	Fallback  string  `json:"fallback"`
+	Color     string  `json:"color"`
+	Pretext   string  `json:"pretext,omitempty"`
+	Title     string  `json:"title"`
+	TitleLink string  `json:"title_link,omitempty"`
+	Text      string  `json:"text,omitempty"`
+	Fields    []Field `json:"fields,omitempty"`
+}
+
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	text, ok := n.Templates.Render(event)
+	details := notify.Details(event)
+	if ok {
+		details = []notify.Paragraph{{Text: text}}
+	}
+
+	return post(ctx, n.WebhookURL, NewMessage(event, details))
+}
+
+// NewMessage lays out the title, details and revision facts of an event as a single attachment
+// linking to the revision, or the service, in the Cloud Console.
+func NewMessage(event notify.RevisionEvent, details []notify.Paragraph) Message {
+	var text []string
+	for _, paragraph := range details {
+		if paragraph.Preformatted {
+			// A fence inside the paragraph would close the code block early
+			text = append(text, "```\n"+strings.ReplaceAll(strings.TrimRight(paragraph.Text, "\n"), "```", "'''")+"\n```")
+		} else {
+			text = append(text, Escape(paragraph.Text))
+		}
+	}
+
+	var fields []Field
+	for _, fact := range notify.Facts(event) {
+		fields = append(fields, Field{Title: fact.Title, Value: "`" + strings.ReplaceAll(fact.Value, "`", "'") + "`", Short: fact.Title != "Image"})
+	}
+
+	attachment := Attachment{
+		Fallback: notify.Summary(event),
+		Color:    fmt.Sprintf("#%06X", notify.Color(event)),
+		Pretext:  Escape(notify.Summary(event)),
+		Title:    notify.Title(event),
+		Text:     strings.Join(text, "\n\n"),
+		Fields:   fields,
+	}
+	if event.Service.ProjectID != "" {
+		attachment.TitleLink = notify.ServiceConsoleURL(event.Service)
+		if event.Revision.Name != "" {
+			attachment.TitleLink = notify.RevisionConsoleURL(event.Service, event.Revision.Name)
+		}
+	}
+
+	return Message{Attachments: []Attachment{attachment}}
+}
+
+// Escape backslash-escapes the characters Mattermost markdown would otherwise interpret.
+func Escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune("\\*_~`|>#[]()@", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// apiError is the body Mattermost answers failed requests with.
+type apiError struct {
+	ID            string `json:"id"`
+	Message       string `json:"message"`
+	DetailedError string `json:"detailed_error"`
+}
+
+// post sends msg. Unlike Slack, Mattermost reports failures with a non-2xx status and a JSON
+// error rather than a plain text reason, and answers successful posts with "ok" or an empty body.
+func post(ctx context.Context, webhookURL string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	var apiErr apiError
+	if json.Unmarshal(answer, &apiErr) != nil || apiErr.Message == "" {
+		return fmt.Errorf("Mattermost returned %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+	if apiErr.DetailedError != "" {
+		apiErr.Message += " (" + apiErr.DetailedError + ")"
+	}
+
+	return fmt.Errorf("Mattermost returned %s: %s: %s", resp.Status, apiErr.ID, apiErr.Message)
+}
@@ -0,0 +1,139 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestMessageGolden compares the message of every event kind with testdata/<kind>.golden.
+// Run go test ./mattermost -update after an intended change of the layout and review the diff.
+func TestMessageGolden(t *testing.T) {
+	for _, kind := range templates.Kinds {
+		if kind == templates.Default {
+			continue
+		}
+
+		event := templates.SampleEvent(kind)
+		got, err := json.MarshalIndent(NewMessage(event, notify.Details(event)), "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkGolden(t, kind+".golden", append(got, '\n'))
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		"*bold* _it_ ~strike~":  `\*bold\* \_it\_ \~strike\~`,
+		"`code` > quote | pipe": "\\`code\\` \\> quote \\| pipe",
+		"[link](x) # heading":   `\[link\]\(x\) \# heading`,
+		"@channel deployed":     `\@channel deployed`,
+		`C:\path`:               `C:\\path`,
+		"api-00002, 100%":       "api-00002, 100%",
+	}
+
+	for text, want := range tests {
+		if got := Escape(text); got != want {
+			t.Errorf("Escape(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestPreformattedFences(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventRevisionFailed))
+	text := NewMessage(event, []notify.Paragraph{{Text: "panic: ```\n@channel *now*\n", Preformatted: true}}).Attachments[0].Text
+
+	if want := "```\npanic: '''\n@channel *now*\n```"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
+
+func TestPostResponses(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	tests := []struct {
+		name    string
+		status  int
+		answer  string
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK, answer: "ok"},
+		{name: "empty", status: http.StatusOK},
+		{name: "json error", status: http.StatusBadRequest,
+			answer:  `{"id":"web.incoming_webhook.parse.app_error","message":"Unable to parse incoming data.","detailed_error":"unexpected EOF","status_code":400}`,
+			wantErr: "Mattermost returned 400 Bad Request: web.incoming_webhook.parse.app_error: Unable to parse incoming data. (unexpected EOF)"},
+		{name: "json error without details", status: http.StatusNotFound,
+			answer:  `{"id":"web.incoming_webhook.invalid.app_error","message":"Invalid webhook."}`,
+			wantErr: "Mattermost returned 404 Not Found: web.incoming_webhook.invalid.app_error: Invalid webhook."},
+		{name: "plain text error", status: http.StatusBadGateway, answer: "bad gateway\n", wantErr: "Mattermost returned 502 Bad Gateway: bad gateway"},
+	}
+
+	for _, test := range tests {
+		var got Message
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.answer))
+		}))
+
+		err := (Notifier{WebhookURL: server.URL + "/hooks/secret-key"}).Notify(context.Background(), event)
+		server.Close()
+
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && (err == nil || err.Error() != test.wantErr):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+		if len(got.Attachments) != 1 || got.Attachments[0].Fallback != notify.Summary(event) {
+			t.Errorf("%s: posted %+v", test.name, got)
+		}
+	}
+}
+
+func TestErrorsKeepTheKeyOut(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for _, webhookURL := range []string{server.URL + "/hooks/secret-key", "https://chat example.com/hooks/secret-key"} {
+		err := (Notifier{WebhookURL: webhookURL}).Notify(context.Background(), templates.SampleEvent(string(diff.EventNewActiveRevision)))
+		if err == nil || strings.Contains(err.Error(), "secret-key") {
+			t.Errorf("posting to %s: got error %v", webhookURL, err)
+		}
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
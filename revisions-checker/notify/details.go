@@ -0,0 +1,239 @@
+package notify
+
+import (
+	"fmt"
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/utils"
+	"strings"
+	"time"
+)
+
+// Fact is one labelled value about an event, e.g. the revision name or the region.
+type Fact struct {
+	Title string
+	Value string
+}
+
+// Title names the kind of event in a few words, without any markup.
+func Title(event RevisionEvent) string {
+	switch event.Kind {
+	case diff.EventNewActiveRevision:
+		return "New Active Revision Detected"
+	case diff.EventRollback:
+		return "Rollback Detected"
+	case diff.EventRevisionDeactivated:
+		return "Revision No Longer Serving"
+	case diff.EventTrafficShifted:
+		return "Traffic Split Changed"
+	case diff.EventActiveRevisionChanged:
+		return "Active Revision Changed"
+	case diff.EventRevisionCreated:
+		return "New Revision Created"
+	case diff.EventRevisionDeleted:
+		return "Revision Deleted"
+	case diff.EventRevisionFailed:
+		return "Revision Failed"
+	case EventMonitoringStarted:
+		return "Now Monitoring Service"
+	default:
+		return string(event.Kind)
+	}
+}
+
+// Color is the accent color of an event kind as an RGB value, e.g. for Discord embeds.
+// Failures are red, rollbacks and revisions stopping to serve amber, deploys green.
+func Color(event RevisionEvent) int {
+	switch event.Kind {
+	case diff.EventRevisionFailed:
+		return 0xD93025
+	case diff.EventRollback, diff.EventRevisionDeactivated, diff.EventRevisionDeleted:
+		return 0xF9AB00
+	case diff.EventNewActiveRevision, diff.EventActiveRevisionChanged:
+		return 0x1E8E3E
+	default:
+		return 0x1A73E8
+	}
+}
+
+// Facts lists the revision an event is about and where it runs.
+func Facts(event RevisionEvent) []Fact {
+	var facts []Fact
+	if event.Revision.Name != "" {
+		facts = append(facts, Fact{"Revision", event.Revision.Name}, Fact{"Image", event.Revision.Image})
+		if event.Revision.TrafficPercent > 0 {
+			traffic := fmt.Sprintf("%d%%", event.Revision.TrafficPercent)
+			if event.Revision.TrafficTag != "" {
+				traffic += fmt.Sprintf(" (tag %s)", event.Revision.TrafficTag)
+			}
+			facts = append(facts, Fact{"Traffic", traffic})
+		}
+		if !event.Revision.CreationTime.IsZero() {
+			facts = append(facts, Fact{"Created", event.Revision.CreationTime.Format(time.RFC1123)})
+		}
+	}
+	facts = append(facts,
+		Fact{"Service", utils.ExtractShortServiceName(event.Service.Name)},
+		Fact{"Project", event.Service.ProjectID},
+		Fact{"Region", event.Service.Region},
+	)
+
+	return facts
+}
+
+// Details describes what changed in plain text paragraphs; blocks meant for a fixed-width
+// font, like the traffic table, are flagged as preformatted.
+func Details(event RevisionEvent) []Paragraph {
+	var paragraphs []Paragraph
+	add := func(text string, preformatted bool) {
+		if text != "" {
+			paragraphs = append(paragraphs, Paragraph{Text: text, Preformatted: preformatted})
+		}
+	}
+
+	switch event.Kind {
+	case diff.EventRollback:
+		if event.Previous != nil {
+			add(fmt.Sprintf("Rolled back from %s (image %s, created %s) to %s.",
+				event.Previous.Name, event.Previous.Image, event.Previous.CreationTime.Format(time.RFC1123), event.Revision.Name), false)
+		} else {
+			add("Traffic went back to an older revision.", false)
+		}
+	case diff.EventRevisionDeactivated:
+		add("The revision no longer receives traffic.", false)
+	case diff.EventTrafficShifted:
+		add(TrafficTable(event.TrafficBefore, event.TrafficAfter), true)
+	case diff.EventActiveRevisionChanged:
+		if len(event.ContainerChanges) == 0 {
+			add("The serving revision was updated in place.", false)
+		}
+	case diff.EventRevisionCreated:
+		add("The revision does not receive traffic yet.", false)
+	case diff.EventRevisionDeleted:
+		add("The revision is no longer listed by Cloud Run.", false)
+	case diff.EventRevisionFailed:
+		add("The revision never became ready:", false)
+		add(event.Revision.Failure, true)
+	case EventMonitoringStarted:
+		var names []string
+		for _, revision := range event.ActiveRevisions {
+			names = append(names, revision.Name)
+		}
+		if len(names) == 0 {
+			names = append(names, "none")
+		}
+		add("Active revisions: "+strings.Join(names, ", "), false)
+	}
+
+	if len(event.Revision.Containers) > 1 {
+		var b strings.Builder
+		for _, container := range event.Revision.Containers {
+			fmt.Fprintf(&b, "%s: %s\n", container.Name, container.Image)
+		}
+		add("Containers:", false)
+		add(b.String(), true)
+	}
+	if len(event.ContainerChanges) > 0 {
+		add("Container image changes:", false)
+		add(ContainerChangesText(event.ContainerChanges), true)
+	}
+	if len(event.SpecChanges) > 0 && event.Previous != nil {
+		add(fmt.Sprintf("Configuration changes vs %s: %d settings (%s)",
+			event.Previous.Name, len(event.SpecChanges), strings.Join(diff.SettingGroups(event.SpecChanges), ", ")), false)
+		add(SpecChangesText(event.SpecChanges), true)
+	}
+
+	return paragraphs
+}
+
+// Paragraph is one block of text of Details.
+type Paragraph struct {
+	Text         string
+	Preformatted bool
+}
+
+// ContainerChangesText lists image changes one container per line.
+func ContainerChangesText(changes []diff.ContainerChange) string {
+	var b strings.Builder
+	for _, change := range changes {
+		switch {
+		case change.PreviousImage == "":
+			fmt.Fprintf(&b, "+ %s: %s\n", change.Name, change.CurrentImage)
+		case change.CurrentImage == "":
+			fmt.Fprintf(&b, "- %s: %s\n", change.Name, change.PreviousImage)
+		default:
+			fmt.Fprintf(&b, "~ %s: %s → %s\n", change.Name, change.PreviousImage, change.CurrentImage)
+		}
+	}
+	return b.String()
+}
+
+// SpecChangesText lists configuration changes one setting per line. Env var values are never shown.
+func SpecChangesText(changes []diff.SettingChange) string {
+	var b strings.Builder
+	for _, change := range changes {
+		switch {
+		case change.Previous == "":
+			if change.Masked() {
+				fmt.Fprintf(&b, "+ %s\n", change.Key)
+			} else {
+				fmt.Fprintf(&b, "+ %s: %s\n", change.Key, change.Current)
+			}
+		case change.Current == "":
+			fmt.Fprintf(&b, "- %s\n", change.Key)
+		case change.Masked():
+			fmt.Fprintf(&b, "~ %s (value changed)\n", change.Key)
+		default:
+			fmt.Fprintf(&b, "~ %s: %s → %s\n", change.Key, change.Previous, change.Current)
+		}
+	}
+	return b.String()
+}
+
+// TrafficTable renders a fixed-width before/after table of the traffic split, one row per revision.
+func TrafficTable(before, after []Revision) string {
+	type row struct {
+		name          string
+		before, after int64
+		tag           string
+	}
+
+	var rows []*row
+	byName := map[string]*row{}
+	get := func(name string) *row {
+		if r, ok := byName[name]; ok {
+			return r
+		}
+		r := &row{name: name}
+		byName[name] = r
+		rows = append(rows, r)
+		return r
+	}
+	for _, revision := range after {
+		r := get(revision.Name)
+		r.after = revision.TrafficPercent
+		r.tag = revision.TrafficTag
+	}
+	for _, revision := range before {
+		r := get(revision.Name)
+		r.before = revision.TrafficPercent
+		if r.tag == "" {
+			r.tag = revision.TrafficTag
+		}
+	}
+
+	width := len("Revision")
+	for _, r := range rows {
+		if len(r.name) > width {
+			width = len(r.name)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-*s  %6s  %6s  %s\n", width, "Revision", "Before", "After", "Tag")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "%-*s  %5d%%  %5d%%  %s\n", width, r.name, r.before, r.after, r.tag)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Named is a notifier along with the name it was configured under.
+type Named struct {
+	Name     string
+	Notifier Notifier
+}
+
+// Delivery is the outcome of sending one event to one destination.
+type Delivery struct {
+	Notifier string
+	Err      error
+}
+
+// Dispatcher fans every event out to all of its notifiers in parallel.
+type Dispatcher struct {
+	notifiers []Named
+}
+
+func NewDispatcher(notifiers ...Named) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers}
+}
+
+// Notify sends event to every notifier and returns one Delivery per notifier, in configuration order.
+// A failing destination does not keep the event from reaching the others.
+func (d *Dispatcher) Notify(ctx context.Context, event RevisionEvent) []Delivery {
+	deliveries := make([]Delivery, len(d.notifiers))
+
+	var wg sync.WaitGroup
+	for i, n := range d.notifiers {
+		wg.Add(1)
+		go func(i int, n Named) {
+			defer wg.Done()
+
+			err := n.Notifier.Notify(ctx, event)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", n.Name, err)
+			}
+			deliveries[i] = Delivery{Notifier: n.Name, Err: err}
+		}(i, n)
+	}
+	wg.Wait()
+
+	return deliveries
+}
@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"errors"
+	"net/url"
+)
+
+// ErrInvalidURL replaces the errors of URLs that cannot be parsed, which quote the URL.
+var ErrInvalidURL = errors.New("invalid URL")
+
+// RedactURLError strips the request URL from the errors of an HTTP client and of url.Parse. Webhook URLs
+// carry their token, key or signature, so they must not end up in logs.
+func RedactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if urlErr.Op == "parse" {
+			// The cause may quote part of the URL too, e.g. an invalid escape
+			return ErrInvalidURL
+		}
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"fmt"
+	"net/url"
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/utils"
+)
+
+// RevisionConsoleURL links to the revision's page in the Cloud Console.
+func RevisionConsoleURL(service Service, revision string) string {
+	return fmt.Sprintf("https://console.cloud.google.com/run/detail/%s/%s/revision/%s?project=%s",
+		service.Region, utils.ExtractShortServiceName(service.Name), revision, url.QueryEscape(service.ProjectID))
+}
+
+// ServiceConsoleURL links to the service's revisions in the Cloud Console.
+func ServiceConsoleURL(service Service) string {
+	return fmt.Sprintf("https://console.cloud.google.com/run/detail/%s/%s/revisions?project=%s",
+		service.Region, utils.ExtractShortServiceName(service.Name), url.QueryEscape(service.ProjectID))
+}
+
+// Summary describes an event in one plain text line, e.g. for notification previews.
+func Summary(event RevisionEvent) string {
+	service := fmt.Sprintf("%s (%s/%s)", utils.ExtractShortServiceName(event.Service.Name), event.Service.ProjectID, event.Service.Region)
+	revision := event.Revision.Name
+
+	switch event.Kind {
+	case diff.EventNewActiveRevision:
+		return fmt.Sprintf("New active revision %s of %s", revision, service)
+	case diff.EventRollback:
+		return fmt.Sprintf("Rollback of %s to %s", service, revision)
+	case diff.EventRevisionDeactivated:
+		return fmt.Sprintf("Revision %s of %s no longer serves traffic", revision, service)
+	case diff.EventTrafficShifted:
+		return fmt.Sprintf("Traffic split of %s changed", service)
+	case diff.EventActiveRevisionChanged:
+		return fmt.Sprintf("Active revision %s of %s changed", revision, service)
+	case diff.EventRevisionCreated:
+		return fmt.Sprintf("New revision %s of %s created", revision, service)
+	case diff.EventRevisionDeleted:
+		return fmt.Sprintf("Revision %s of %s deleted", revision, service)
+	case diff.EventRevisionFailed:
+		return fmt.Sprintf("Revision %s of %s failed to deploy", revision, service)
+	case EventMonitoringStarted:
+		return fmt.Sprintf("Now monitoring %s", service)
+	default:
+		return fmt.Sprintf("%s: %s %s", event.Kind, service, revision)
+	}
+}
@@ -0,0 +1,26 @@
+package notify
+
+import (
+	"context"
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"time"
+)
+
+// EventMonitoringStarted is sent once per service when it is checked for the first time
+// under the announce first run policy.
+const EventMonitoringStarted diff.EventKind = "monitoring-started"
+
+// RevisionEvent is everything a notifier needs to describe one change of a service.
+type RevisionEvent struct {
+	diff.Event
+	Service Service
+	// ActiveRevisions lists the revisions serving traffic when the event was detected.
+	ActiveRevisions []Revision
+	Time            time.Time
+}
+
+// Notifier delivers revision events to one destination, e.g. a Slack channel.
+type Notifier interface {
+	Notify(ctx context.Context, event RevisionEvent) error
+}
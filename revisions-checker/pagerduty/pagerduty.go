@@ -0,0 +1,198 @@
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	. "revisions-checker/common"
+	"revisions-checker/config"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/utils"
+)
+
+// DefaultEndpoint is the base URL of the Events API v2.
+const DefaultEndpoint = "https://events.pagerduty.com"
+
+// Notifier reports revision events to PagerDuty through the Events API v2:
+//   - new active revisions and rollbacks are sent as change events,
+//   - failed revisions trigger an alert deduplicated per service,
+//   - that alert is resolved, on a best effort basis, as soon as a revision of the service becomes active.
+//
+// Other events are ignored, as are services no route matches when there is no default routing key.
+type Notifier struct {
+	// Endpoint overrides DefaultEndpoint, e.g. for a proxy
+	Endpoint   string
+	RoutingKey string
+	Routes     []config.Route
+}
+
+// Event is an alert event, see https://developer.pagerduty.com/docs/events-api-v2/trigger-events/.
+type Event struct {
+	RoutingKey  string   `json:"routing_key"`
+	EventAction string   `json:"event_action"`
+	DedupKey    string   `json:"dedup_key,omitempty"`
+	Payload     *Payload `json:"payload,omitempty"`
+	Links       []Link   `json:"links,omitempty"`
+}
+
+// ChangeEvent is a change event, see https://developer.pagerduty.com/docs/events-api-v2/send-change-events/.
+type ChangeEvent struct {
+	RoutingKey string  `json:"routing_key"`
+	Payload    Payload `json:"payload"`
+	Links      []Link  `json:"links,omitempty"`
+}
+
+// Payload describes an event; change events only use Summary, Source, Timestamp and CustomDetails.
+type Payload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity,omitempty"`
+	Timestamp     string                 `json:"timestamp,omitempty"`
+	Component     string                 `json:"component,omitempty"`
+	Group         string                 `json:"group,omitempty"`
+	Class         string                 `json:"class,omitempty"`
+	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
+}
+
+type Link struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	routingKey := n.routingKey(event.Service)
+	if routingKey == "" {
+		return nil
+	}
+
+	switch event.Kind {
+	case diff.EventNewActiveRevision, diff.EventRollback:
+		change := ChangeEvent{RoutingKey: routingKey, Payload: payload(event), Links: links(event)}
+		if err := n.post(ctx, "/v2/change/enqueue", change); err != nil {
+			return err
+		}
+		// A healthy revision took over, so an earlier failure no longer needs attention. PagerDuty ignores
+		// the resolve when nothing was triggered; a failure is only logged, as returning it would have the
+		// change event sent again
+		resolve := Event{RoutingKey: routingKey, EventAction: "resolve", DedupKey: DedupKey(event.Service)}
+		if err := n.post(ctx, "/v2/enqueue", resolve); err != nil {
+			log.Printf("Error resolving the failed revision alert of %s: %v", event.Service.Name, err)
+		}
+		return nil
+	case diff.EventRevisionFailed:
+		alert := payload(event)
+		alert.Severity = "error"
+		alert.Class = "revision-failed"
+		alert.Component = utils.ExtractShortServiceName(event.Service.Name)
+		alert.Group = event.Service.ProjectID + "/" + event.Service.Region
+		return n.post(ctx, "/v2/enqueue", Event{RoutingKey: routingKey, EventAction: "trigger", DedupKey: DedupKey(event.Service),
+			Payload: &alert, Links: links(event)})
+	default:
+		return nil
+	}
+}
+
+// DedupKey identifies the alert of a service's failed revisions; further failures update the same alert.
+func DedupKey(service Service) string {
+	return "cloud-run-revision-failed/" + service.Name
+}
+
+// routingKey returns the key of the first route matching service, falling back to RoutingKey.
+func (n Notifier) routingKey(service Service) string {
+	for _, route := range n.Routes {
+		if route.Service != "" && route.Service == utils.ExtractShortServiceName(service.Name) {
+			return route.RoutingKey
+		}
+		if key, value, ok := strings.Cut(route.Label, "="); ok {
+			if actual, present := service.Labels[key]; present && actual == value {
+				return route.RoutingKey
+			}
+		}
+	}
+
+	return n.RoutingKey
+}
+
+func payload(event notify.RevisionEvent) Payload {
+	timestamp := event.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	details := map[string]interface{}{
+		"kind":     string(event.Kind),
+		"revision": event.Revision.Name,
+		"image":    event.Revision.Image,
+		"project":  event.Service.ProjectID,
+		"region":   event.Service.Region,
+	}
+	if event.Revision.TrafficPercent > 0 {
+		details["traffic_percent"] = event.Revision.TrafficPercent
+	}
+	if event.Previous != nil {
+		details["previous_revision"] = event.Previous.Name
+		details["previous_image"] = event.Previous.Image
+	}
+	if event.Revision.Failure != "" {
+		details["failure"] = event.Revision.Failure
+	}
+
+	return Payload{
+		Summary:       notify.Summary(event),
+		Source:        event.Service.Name,
+		Timestamp:     timestamp.UTC().Format(time.RFC3339),
+		CustomDetails: details,
+	}
+}
+
+func links(event notify.RevisionEvent) []Link {
+	var l []Link
+	if event.Revision.Name != "" && event.Service.ProjectID != "" {
+		l = append(l, Link{Href: notify.RevisionConsoleURL(event.Service, event.Revision.Name), Text: "Revision in Cloud Console"})
+	}
+	if event.Service.ProjectID != "" {
+		l = append(l, Link{Href: notify.ServiceConsoleURL(event.Service), Text: "Service in Cloud Console"})
+	}
+
+	return l
+}
+
+// post sends body to the Events API, which answers 202 Accepted for every accepted event.
+func (n Notifier) post(ctx context.Context, path string, body interface{}) error {
+	endpoint := n.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("PagerDuty returned %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"revisions-checker/config"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// request is a request received by the fake Events API, decoded loosely.
+type request struct {
+	Path string
+	Body map[string]interface{}
+}
+
+// fakeAPI records the requests it receives and answers status, or 202 when status is 0.
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests []request
+	status   map[string]int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request{Path: r.URL.Path, Body: body})
+	if status := f.status[r.URL.Path]; status != 0 {
+		http.Error(w, `{"status":"invalid event","message":"Event object is invalid"}`, status)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write([]byte(`{"status":"success","message":"Event processed"}`))
+}
+
+func newFakeAPI(t *testing.T, status map[string]int) (*fakeAPI, string) {
+	api := &fakeAPI{status: status}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return api, server.URL
+}
+
+func TestNotifyPayloads(t *testing.T) {
+	tests := []struct {
+		kind string
+		// want is "<path> <event_action>" per request, the action being empty for change events
+		want []string
+	}{
+		{kind: string(diff.EventNewActiveRevision), want: []string{"/v2/change/enqueue ", "/v2/enqueue resolve"}},
+		{kind: string(diff.EventRollback), want: []string{"/v2/change/enqueue ", "/v2/enqueue resolve"}},
+		{kind: string(diff.EventRevisionFailed), want: []string{"/v2/enqueue trigger"}},
+		{kind: string(diff.EventTrafficShifted)},
+		{kind: string(diff.EventRevisionCreated)},
+	}
+
+	for _, test := range tests {
+		api, endpoint := newFakeAPI(t, nil)
+		event := templates.SampleEvent(test.kind)
+
+		if err := (Notifier{Endpoint: endpoint, RoutingKey: "R1"}).Notify(context.Background(), event); err != nil {
+			t.Errorf("%s: %v", test.kind, err)
+			continue
+		}
+
+		var got []string
+		for _, r := range api.requests {
+			action, _ := r.Body["event_action"].(string)
+			got = append(got, r.Path+" "+action)
+			if r.Body["routing_key"] != "R1" {
+				t.Errorf("%s: %s sent with routing key %v", test.kind, r.Path, r.Body["routing_key"])
+			}
+
+			switch action {
+			case "":
+				payload := r.Body["payload"].(map[string]interface{})
+				if payload["summary"] != notify.Summary(event) || payload["source"] != event.Service.Name {
+					t.Errorf("%s: change event payload %v", test.kind, payload)
+				}
+			case "trigger":
+				payload := r.Body["payload"].(map[string]interface{})
+				details := payload["custom_details"].(map[string]interface{})
+				if payload["severity"] != "error" || payload["class"] != "revision-failed" || details["failure"] != event.Revision.Failure {
+					t.Errorf("%s: alert payload %v", test.kind, payload)
+				}
+				fallthrough
+			case "resolve":
+				if r.Body["dedup_key"] != DedupKey(event.Service) {
+					t.Errorf("%s: %s with dedup key %v, want %s", test.kind, action, r.Body["dedup_key"], DedupKey(event.Service))
+				}
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%s: sent %q, want %q", test.kind, got, test.want)
+		}
+	}
+}
+
+func TestFailedResolveIsNotRetried(t *testing.T) {
+	api, endpoint := newFakeAPI(t, map[string]int{"/v2/enqueue": http.StatusInternalServerError})
+
+	err := (Notifier{Endpoint: endpoint, RoutingKey: "R1"}).Notify(context.Background(), templates.SampleEvent(string(diff.EventNewActiveRevision)))
+	if err != nil {
+		t.Errorf("a failed resolve failed the delivery, which would send the change event again: %v", err)
+	}
+	if len(api.requests) != 2 {
+		t.Errorf("sent %d requests, want the change event and the resolve", len(api.requests))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, endpoint := newFakeAPI(t, map[string]int{"/v2/change/enqueue": http.StatusBadRequest, "/v2/enqueue": http.StatusBadRequest})
+
+	for _, kind := range []string{string(diff.EventNewActiveRevision), string(diff.EventRevisionFailed)} {
+		err := (Notifier{Endpoint: endpoint, RoutingKey: "R1"}).Notify(context.Background(), templates.SampleEvent(kind))
+		if err == nil || !strings.Contains(err.Error(), "PagerDuty returned 400 Bad Request") {
+			t.Errorf("%s: got error %v", kind, err)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventRevisionFailed))
+	event.Service.Labels = map[string]string{"team": "payments"}
+
+	tests := []struct {
+		name     string
+		notifier Notifier
+		want     string
+	}{
+		{name: "service route", notifier: Notifier{RoutingKey: "default", Routes: []config.Route{{Service: "other", RoutingKey: "R-other"}, {Service: "sample", RoutingKey: "R-sample"}}}, want: "R-sample"},
+		{name: "label route", notifier: Notifier{RoutingKey: "default", Routes: []config.Route{{Label: "team=payments", RoutingKey: "R-payments"}}}, want: "R-payments"},
+		{name: "no matching route", notifier: Notifier{RoutingKey: "default", Routes: []config.Route{{Label: "team=search", RoutingKey: "R-search"}}}, want: "default"},
+		{name: "no route and no default", notifier: Notifier{Routes: []config.Route{{Service: "other", RoutingKey: "R-other"}}}},
+	}
+
+	for _, test := range tests {
+		api, endpoint := newFakeAPI(t, nil)
+		test.notifier.Endpoint = endpoint
+		if err := test.notifier.Notify(context.Background(), event); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		var got string
+		if len(api.requests) > 0 {
+			got, _ = api.requests[0].Body["routing_key"].(string)
+		}
+		if got != test.want {
+			t.Errorf("%s: routed to %q, want %q", test.name, got, test.want)
+		}
+	}
+}
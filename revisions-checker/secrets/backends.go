@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/secretmanager/v1"
+)
+
+// EnvResolver resolves "env://NAME" from the process environment.
+type EnvResolver struct{}
+
+func (EnvResolver) Resolve(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return value, nil
+}
+
+// FileResolver resolves "file:///path" from the file contents, without the trailing newline.
+type FileResolver struct{}
+
+func (FileResolver) Resolve(ctx context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// SecretManagerResolver resolves "secret://projects/x/secrets/y/versions/z" through Secret Manager.
+// The client is created on first use so runs without secret:// references need no credentials.
+type SecretManagerResolver struct {
+	// Endpoint overrides the Secret Manager API endpoint, e.g. to point at a local fake server.
+	// Plain http:// endpoints are called without authentication.
+	Endpoint string
+
+	once    sync.Once
+	service *secretmanager.Service
+	err     error
+}
+
+func (r *SecretManagerResolver) Resolve(ctx context.Context, name string) (string, error) {
+	r.once.Do(func() {
+		var opts []option.ClientOption
+		if r.Endpoint != "" {
+			opts = append(opts, option.WithEndpoint(r.Endpoint))
+			if strings.HasPrefix(r.Endpoint, "http://") {
+				opts = append(opts, option.WithoutAuthentication())
+			}
+		}
+		r.service, r.err = secretmanager.NewService(ctx, opts...)
+	})
+	if r.err != nil {
+		return "", fmt.Errorf("secretmanager.NewService: %w", r.err)
+	}
+
+	resp, err := r.service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding payload of %s: %w", name, err)
+	}
+
+	return string(data), nil
+}
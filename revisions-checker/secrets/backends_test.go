@@ -0,0 +1,47 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSecretManagerResolver(t *testing.T) {
+	const name = "projects/p/secrets/slack-webhook/versions/latest"
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/"+name+":access" {
+			http.Error(w, `{"error":{"code":404,"message":"secret not found","status":"NOT_FOUND"}}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":    name,
+			"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte("https://hooks.slack.com/services/T/B/secret"))},
+		})
+	}))
+	defer server.Close()
+
+	registry := NewDefaultRegistry(server.URL + "/")
+	for i := 0; i < 2; i++ {
+		got, err := registry.Resolve(context.Background(), "secret://"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "https://hooks.slack.com/services/T/B/secret" {
+			t.Errorf("got %q", got)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Secret Manager was called %d times, want once", requests)
+	}
+
+	if _, err := registry.Resolve(context.Background(), "secret://projects/p/secrets/missing/versions/1"); err == nil || !strings.Contains(err.Error(), "secret not found") {
+		t.Errorf("got error %v for a missing secret", err)
+	}
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+var (
+	redactionsMu sync.RWMutex
+	redactions   []string
+)
+
+// AddRedaction makes Redact hide every occurrence of value.
+func AddRedaction(value string) {
+	if value == "" {
+		return
+	}
+
+	redactionsMu.Lock()
+	defer redactionsMu.Unlock()
+
+	for _, existing := range redactions {
+		if existing == value {
+			return
+		}
+	}
+	redactions = append(redactions, value)
+}
+
+// Redact replaces every resolved secret in s with a placeholder.
+func Redact(s string) string {
+	redactionsMu.RLock()
+	defer redactionsMu.RUnlock()
+
+	for _, value := range redactions {
+		s = strings.ReplaceAll(s, value, redactedPlaceholder)
+	}
+
+	return s
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter wraps w so that resolved secrets never reach it, e.g. log.SetOutput(NewRedactingWriter(os.Stderr)).
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return redactingWriter{w: w}
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
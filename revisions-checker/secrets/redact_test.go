@@ -0,0 +1,24 @@
+package secrets
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	AddRedaction("hunter2")
+	AddRedaction("hunter2")
+	AddRedaction("")
+
+	var out bytes.Buffer
+	logger := log.New(NewRedactingWriter(&out), "", 0)
+	logger.Printf("posting to https://example.com/hook?token=hunter2 failed, retrying with hunter2")
+
+	if got, want := out.String(), "posting to https://example.com/hook?token=[REDACTED] failed, retrying with [REDACTED]\n"; got != want {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+	if got := Redact("nothing secret here"); got != "nothing secret here" {
+		t.Errorf("Redact changed a string without secrets: %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// SecretResolver fetches the value behind a secret reference.
+// The name is the part of the reference after "<scheme>://".
+type SecretResolver interface {
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// Registry dispatches references such as "env://NAME" to the resolver registered for their scheme.
+// Resolved values are cached for the lifetime of the Registry and registered for log redaction.
+type Registry struct {
+	mu        sync.Mutex
+	resolvers map[string]SecretResolver
+	cache     map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		resolvers: map[string]SecretResolver{},
+		cache:     map[string]string{},
+	}
+}
+
+// Register makes resolver handle every reference starting with "<scheme>://".
+func (r *Registry) Register(scheme string, resolver SecretResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resolvers[scheme] = resolver
+}
+
+// IsReference reports whether value has the "<scheme>://" shape of a secret reference
+// using one of the registered schemes.
+func (r *Registry) IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok = r.resolvers[scheme]
+	return ok
+}
+
+// Resolve returns the secret behind value, or value itself when it is not a reference.
+func (r *Registry) Resolve(ctx context.Context, value string) (string, error) {
+	if !r.IsReference(value) {
+		return value, nil
+	}
+
+	r.mu.Lock()
+	if cached, ok := r.cache[value]; ok {
+		r.mu.Unlock()
+		return cached, nil
+	}
+	scheme, name, _ := strings.Cut(value, "://")
+	resolver := r.resolvers[scheme]
+	r.mu.Unlock()
+
+	secret, err := resolver.Resolve(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("resolving secret %q: %w", value, err)
+	}
+
+	r.mu.Lock()
+	r.cache[value] = secret
+	r.mu.Unlock()
+
+	AddRedaction(secret)
+
+	return secret, nil
+}
+
+// NewDefaultRegistry returns a Registry with the env://, file:// and secret:// backends.
+func NewDefaultRegistry(secretManagerEndpoint string) *Registry {
+	registry := NewRegistry()
+	registry.Register("env", EnvResolver{})
+	registry.Register("file", FileResolver{})
+	registry.Register("secret", &SecretManagerResolver{Endpoint: secretManagerEndpoint})
+
+	return registry
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingResolver answers "value-of-<name>" and counts its calls.
+type countingResolver struct {
+	calls int
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, name string) (string, error) {
+	r.calls++
+	return "value-of-" + name, nil
+}
+
+func TestRegistryResolve(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("RCN_TEST_TOKEN", "xoxb-from-env")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := NewDefaultRegistry("")
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string
+	}{
+		{value: "env://RCN_TEST_TOKEN", want: "xoxb-from-env"},
+		{value: "file://" + path, want: "from-file"},
+		{value: "https://hooks.slack.com/services/T/B/x", want: "https://hooks.slack.com/services/T/B/x"},
+		{value: "plain value", want: "plain value"},
+		{value: "", want: ""},
+		{value: "env://RCN_TEST_UNSET", wantErr: `resolving secret "env://RCN_TEST_UNSET": environment variable RCN_TEST_UNSET is not set`},
+		{value: "file://" + filepath.Join(t.TempDir(), "missing"), wantErr: "no such file"},
+	}
+
+	for _, test := range tests {
+		got, err := registry.Resolve(ctx, test.value)
+		switch {
+		case test.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got %q, %v, want error %q", test.value, got, err, test.wantErr)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", test.value, err)
+		case got != test.want:
+			t.Errorf("%s: got %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestRegistryCachesAndRedacts(t *testing.T) {
+	ctx := context.Background()
+	resolver := &countingResolver{}
+	registry := NewRegistry()
+	registry.Register("vault", resolver)
+
+	for i := 0; i < 3; i++ {
+		got, err := registry.Resolve(ctx, "vault://cached-token")
+		if err != nil || got != "value-of-cached-token" {
+			t.Fatalf("got %q, %v", got, err)
+		}
+	}
+	if resolver.calls != 1 {
+		t.Errorf("resolved %d times, want once", resolver.calls)
+	}
+
+	if !registry.IsReference("vault://x") || registry.IsReference("env://X") || registry.IsReference("vault:x") {
+		t.Error("IsReference must only accept the registered schemes")
+	}
+	if got := Redact("token=value-of-cached-token"); got != "token="+redactedPlaceholder {
+		t.Errorf("resolved secrets are not redacted: %q", got)
+	}
+}
@@ -0,0 +1,368 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"revisions-checker/cloudrun"
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	. "revisions-checker/state"
+	"revisions-checker/utils"
+)
+
+// Action IDs of the interactive buttons.
+const (
+	ActionAcknowledge = "acknowledge"
+	ActionRollback    = "rollback"
+)
+
+// History events recorded for button clicks, along with the Slack user who clicked.
+// A rollback is recorded as requested before the traffic is touched, then as done or failed.
+const (
+	HistoryAcknowledged      = "acknowledged"
+	HistoryRollbackRequested = "rollback-requested"
+	HistoryRollback          = "rollback"
+	HistoryRollbackDenied    = "rollback-denied"
+	HistoryRollbackFailed    = "rollback-failed"
+)
+
+// MaxRequestAge is how old a signed request may be before it is refused as a possible replay.
+const MaxRequestAge = 5 * time.Minute
+
+// ActionTimeout bounds the handling of a click once it is acknowledged, rollout of a rollback included;
+// the timeout of the function serving the requests should be longer.
+const ActionTimeout = 3 * time.Minute
+
+// actionValue is the value of the interactive buttons: the service and the revision the message is about.
+type actionValue struct {
+	Service  string `json:"service"`
+	Revision string `json:"revision"`
+}
+
+// addActionButtons adds an Acknowledge button to new revision messages, and a Roll back button
+// when the revision replaced another one.
+func addActionButtons(payload *SlackRequestBody, event notify.RevisionEvent) {
+	if event.Kind != diff.EventNewActiveRevision || event.Service.Name == "" {
+		return
+	}
+
+	value := actionValue{Service: event.Service.Name, Revision: event.Revision.Name}
+	buttons := []interface{}{actionButton("Acknowledge", ActionAcknowledge, value, "primary", nil)}
+	if event.Previous != nil {
+		// The revision to go back to is looked up again on click, the traffic may have moved since
+		buttons = append(buttons, actionButton("Roll back to previous revision", ActionRollback, value, "danger", &Confirm{
+			Title: TextObject{Type: "plain_text", Text: "Roll back?"},
+			Text: *mrkdwn(fmt.Sprintf("Send 100%% of the traffic of `%s` back to the revision serving before `%s`?",
+				utils.ExtractShortServiceName(event.Service.Name), event.Revision.Name)),
+			Confirm: TextObject{Type: "plain_text", Text: "Roll back"},
+			Deny:    TextObject{Type: "plain_text", Text: "Cancel"},
+			Style:   "danger",
+		}))
+	}
+
+	for i := range payload.Blocks {
+		if payload.Blocks[i].Type == "actions" {
+			payload.Blocks[i].Elements = append(buttons, payload.Blocks[i].Elements...)
+			return
+		}
+	}
+	payload.Blocks = append(payload.Blocks, Block{Type: "actions", Elements: buttons})
+}
+
+func actionButton(text, actionID string, value actionValue, style string, confirm *Confirm) Button {
+	data, _ := json.Marshal(value)
+	return Button{Type: "button", Text: TextObject{Type: "plain_text", Text: text}, ActionID: actionID, Value: string(data), Style: style, Confirm: confirm}
+}
+
+// ActionHandler serves the interactivity request URL of the Slack app. It verifies requests with the
+// app's signing secret and handles the buttons of new revision messages: acknowledgements are recorded
+// in the history of the service, rollbacks send all the traffic back to the revision serving before the
+// one of the message when the user is on the allowlist of the service and are recorded whether they are
+// allowed or not. Clicks are acknowledged right away and answered through their response_url.
+type ActionHandler struct {
+	SigningSecret string
+	Client        cloudrun.CloudRunClient
+	Store         StateStore
+	// HistoryPrefix is the prefix of the history keys, see DocumentName
+	HistoryPrefix string
+	// Allowlist maps services to the Slack user IDs allowed to roll them back, see allowed
+	Allowlist map[string][]string
+}
+
+// interaction is the part of a block_actions payload used here,
+// see https://api.slack.com/reference/interaction-payloads/block-actions.
+type interaction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	ResponseURL string `json:"response_url"`
+	Actions     []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+func (h ActionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "unreadable body", http.StatusBadRequest)
+		return
+	}
+	if err := VerifyRequest(h.SigningSecret, r.Header, body, time.Now()); err != nil {
+		log.Printf("Refusing Slack request: %v", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	var payload interaction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if payload.Type != "block_actions" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Slack shows an error for clicks not acknowledged within 3 seconds, which a rollback easily takes,
+	// so the empty answer is sent first and the outcome is posted to the response_url
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	// The request context ends as soon as Slack hangs up
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), ActionTimeout)
+	defer cancel()
+
+	for _, action := range payload.Actions {
+		if action.ActionID != ActionAcknowledge && action.ActionID != ActionRollback {
+			// Link buttons are reported too
+			continue
+		}
+		var value actionValue
+		if err := json.Unmarshal([]byte(action.Value), &value); err != nil {
+			log.Printf("Ignoring %s action with an invalid value: %v", action.ActionID, err)
+			continue
+		}
+
+		reply := h.handle(ctx, payload.User.ID, action.ActionID, value)
+		if err := respond(ctx, payload.ResponseURL, reply); err != nil {
+			log.Printf("Error answering the %s action of %s: %v", action.ActionID, payload.User.ID, err)
+		}
+	}
+}
+
+// response is posted to the response_url of an interaction.
+type response struct {
+	ResponseType    string `json:"response_type"`
+	ReplaceOriginal bool   `json:"replace_original"`
+	Text            string `json:"text"`
+}
+
+// handle performs an action and records it, returning the message to answer the user with.
+func (h ActionHandler) handle(ctx context.Context, userID, actionID string, value actionValue) response {
+	service := serviceOf(value.Service)
+	record := func(entry HistoryEntry) error {
+		entry.Time, entry.Actor = time.Now().UTC(), "slack:"+userID
+		err := h.Store.AppendHistory(ctx, DocumentName(h.HistoryPrefix, service), []HistoryEntry{entry})
+		if err != nil {
+			log.Printf("Error recording the %s of %s by %s: %v", entry.Event, service.Name, userID, err)
+		}
+		return err
+	}
+
+	if actionID == ActionRollback {
+		return h.rollback(ctx, service, userID, value.Revision, record)
+	}
+
+	if err := record(HistoryEntry{Event: HistoryAcknowledged, Revision: Revision{Name: value.Revision}}); err != nil {
+		return response{ResponseType: "ephemeral", Text: fmt.Sprintf(":warning: Your acknowledgement of `%s` could not be recorded: %v", value.Revision, err)}
+	}
+	return response{ResponseType: "in_channel", Text: fmt.Sprintf(":white_check_mark: <@%s> acknowledged `%s`.", userID, value.Revision)}
+}
+
+// rollback sends all the traffic of service back to the revision serving before revision. Nothing is
+// changed unless the request could be recorded first, so every rollback can be traced to a user.
+func (h ActionHandler) rollback(ctx context.Context, service Service, userID, revision string, record func(HistoryEntry) error) response {
+	name := utils.ExtractShortServiceName(service.Name)
+	deny := func(note, text string) response {
+		// A refusal changes nothing, failing to record it is only logged
+		record(HistoryEntry{Event: HistoryRollbackDenied, Revision: Revision{Name: revision}, Note: note})
+		return response{ResponseType: "ephemeral", Text: text}
+	}
+
+	if !h.allowed(service, userID) {
+		return deny("not on the allowlist", fmt.Sprintf(":no_entry: You are not allowed to roll back `%s`.", name))
+	}
+	target, err := h.rollbackTarget(ctx, service, revision)
+	if err != nil {
+		return deny(err.Error(), fmt.Sprintf(":no_entry: Cannot roll back `%s`: %v.", name, err))
+	}
+
+	note := "from " + revision
+	if err := record(HistoryEntry{Event: HistoryRollbackRequested, Revision: Revision{Name: target}, Note: note}); err != nil {
+		return response{ResponseType: "ephemeral", Text: fmt.Sprintf(":x: Did not roll back `%s`, the request could not be recorded: %v", name, err)}
+	}
+
+	if err := cloudrun.RouteAllTraffic(ctx, h.Client, service.Name, target); err != nil {
+		reply := response{ResponseType: "ephemeral", Text: fmt.Sprintf(":x: Rolling back `%s` to `%s` failed: %v", name, target, err)}
+		if recordErr := record(HistoryEntry{Event: HistoryRollbackFailed, Revision: Revision{Name: target}, Note: err.Error()}); recordErr != nil {
+			reply.Text += fmt.Sprintf("\n:warning: The failure could not be recorded: %v", recordErr)
+		}
+		return reply
+	}
+
+	reply := response{ResponseType: "in_channel", Text: fmt.Sprintf(":rewind: <@%s> rolled `%s` back from `%s` to `%s`.", userID, name, revision, target)}
+	if err := record(HistoryEntry{Event: HistoryRollback, Revision: Revision{Name: target}, Note: note}); err != nil {
+		reply.Text += fmt.Sprintf("\n:warning: The rollback could not be recorded, only its request was: %v", err)
+	}
+	return reply
+}
+
+// rollbackTarget looks up, when the button is clicked, the main revision serving before revision last
+// started serving. Rolling back a revision that no longer serves traffic, e.g. because someone already
+// rolled it back or deployed again, is refused.
+func (h ActionHandler) rollbackTarget(ctx context.Context, service Service, revision string) (string, error) {
+	live, err := h.Client.GetService(ctx, service.Name)
+	if err != nil {
+		return "", fmt.Errorf("reading the service: %w", err)
+	}
+	serving := false
+	for _, status := range live.TrafficStatuses {
+		if status.Revision == revision && status.Percent > 0 {
+			serving = true
+		}
+	}
+	if !serving {
+		return "", fmt.Errorf("`%s` no longer serves traffic", revision)
+	}
+
+	history, err := h.Store.GetHistory(ctx, DocumentName(h.HistoryPrefix, service))
+	if err != nil {
+		return "", fmt.Errorf("reading the history: %w", err)
+	}
+	var target TrafficAllocation
+	for _, allocation := range ServingBefore(history, revision) {
+		if allocation.Percent > target.Percent {
+			target = allocation
+		}
+	}
+	if target.Revision == "" {
+		return "", fmt.Errorf("no revision is known to have served before `%s`", revision)
+	}
+
+	return target.Revision, nil
+}
+
+// allowed looks userID up in the allowlist entries of service: "<project>/<region>/<service>", then the
+// short service name, which applies to the services of that name in every project and region, then "*".
+func (h ActionHandler) allowed(service Service, userID string) bool {
+	name := utils.ExtractShortServiceName(service.Name)
+	for _, key := range []string{service.ProjectID + "/" + service.Region + "/" + name, name, "*"} {
+		for _, allowed := range h.Allowlist[key] {
+			if allowed == userID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// serviceOf rebuilds a Service from its full resource name, "projects/p/locations/r/services/s".
+func serviceOf(name string) Service {
+	service := Service{Name: name}
+	parts := strings.Split(name, "/")
+	for i := 0; i+1 < len(parts); i += 2 {
+		switch parts[i] {
+		case "projects":
+			service.ProjectID = parts[i+1]
+		case "locations":
+			service.Region = parts[i+1]
+		}
+	}
+
+	return service
+}
+
+func respond(ctx context.Context, responseURL string, reply response) error {
+	if responseURL == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Slack returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+// VerifyRequest checks the X-Slack-Signature of a request against the signing secret of the app,
+// see https://api.slack.com/authentication/verifying-requests-from-slack.
+func VerifyRequest(signingSecret string, header http.Header, body []byte, now time.Time) error {
+	if signingSecret == "" {
+		return errors.New("no signing secret configured")
+	}
+
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > MaxRequestAge || age < -MaxRequestAge {
+		return fmt.Errorf("timestamp %s is too far from now", timestamp)
+	}
+
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return errors.New("signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+package slack
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"revisions-checker/cloudrun"
+	. "revisions-checker/common"
+	. "revisions-checker/state"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// brokenHistory is a store whose history cannot be written.
+type brokenHistory struct {
+	StateStore
+}
+
+func (brokenHistory) AppendHistory(ctx context.Context, key string, entries []HistoryEntry) error {
+	return errors.New("firestore unavailable")
+}
+
+// actionFixture is a service that served api-00001 and now serves api-00002, with its history.
+type actionFixture struct {
+	client    *cloudrun.FakeClient
+	store     StateStore
+	service   Service
+	replies   chan response
+	responder *httptest.Server
+}
+
+func newActionFixture(t *testing.T) *actionFixture {
+	f := &actionFixture{client: cloudrun.NewFakeClient(), replies: make(chan response, 10)}
+	name := f.client.AddService("p", "us-central1", "api")
+	f.service = Service{Name: name, ProjectID: "p", Region: "us-central1"}
+	f.client.Deploy(name, "api:1")
+	f.client.Deploy(name, "api:2")
+
+	f.store = NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := f.store.AppendHistory(context.Background(), DocumentName("history.", f.service), []HistoryEntry{
+		{Time: start, Event: "observed", Revision: Revision{Name: "api-00001"}, Serving: []TrafficAllocation{{Revision: "api-00001", Percent: 100}}},
+		{Time: start.Add(time.Hour), Event: "new-active", Revision: Revision{Name: "api-00002"}, Serving: []TrafficAllocation{{Revision: "api-00002", Percent: 100}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.responder = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reply response
+		json.NewDecoder(r.Body).Decode(&reply)
+		f.replies <- reply
+	}))
+	t.Cleanup(f.responder.Close)
+
+	return f
+}
+
+func (f *actionFixture) handler(store StateStore) ActionHandler {
+	return ActionHandler{SigningSecret: testSigningSecret, Client: f.client, Store: store, HistoryPrefix: "history.",
+		Allowlist: map[string][]string{"p/us-central1/api": {"U1"}}}
+}
+
+// click sends a signed click on a button of the message about revision and returns the answer posted to the response_url.
+func (f *actionFixture) click(t *testing.T, handler ActionHandler, userID, actionID, revision string) response {
+	value, _ := json.Marshal(actionValue{Service: f.service.Name, Revision: revision})
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type":         "block_actions",
+		"user":         map[string]string{"id": userID},
+		"response_url": f.responder.URL,
+		"actions":      []map[string]string{{"action_id": actionID, "value": string(value)}},
+	})
+	body := "payload=" + url.QueryEscape(string(payload))
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", sign(testSigningSecret, timestamp, body))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("click answered %d %q, want an empty 200", w.Code, w.Body.String())
+	}
+
+	select {
+	case reply := <-f.replies:
+		return reply
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing was posted to the response_url")
+		return response{}
+	}
+}
+
+func (f *actionFixture) serving(t *testing.T) string {
+	service, err := f.client.GetService(context.Background(), f.service.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serving []string
+	for _, status := range service.TrafficStatuses {
+		serving = append(serving, fmt.Sprintf("%s=%d", status.Revision, status.Percent))
+	}
+	return strings.Join(serving, ",")
+}
+
+func (f *actionFixture) historyEvents(t *testing.T) []string {
+	history, err := f.store.GetHistory(context.Background(), DocumentName("history.", f.service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	for _, entry := range history {
+		if entry.Actor != "" {
+			events = append(events, entry.Event+":"+entry.Revision.Name)
+		}
+	}
+	return events
+}
+
+func TestRollbackResolvesTheTargetOnClick(t *testing.T) {
+	f := newActionFixture(t)
+	handler := f.handler(f.store)
+
+	reply := f.click(t, handler, "U1", ActionRollback, "api-00002")
+	if reply.ResponseType != "in_channel" || !strings.Contains(reply.Text, "back from `api-00002` to `api-00001`") {
+		t.Errorf("reply %+v", reply)
+	}
+	if got := f.serving(t); got != "api-00001=100" {
+		t.Errorf("serving %s after the rollback", got)
+	}
+
+	// The message is stale now, clicking again must not move the traffic anywhere
+	reply = f.click(t, handler, "U1", ActionRollback, "api-00002")
+	if reply.ResponseType != "ephemeral" || !strings.Contains(reply.Text, "no longer serves traffic") {
+		t.Errorf("second click answered %+v", reply)
+	}
+
+	want := "rollback-requested:api-00001 rollback:api-00001 rollback-denied:api-00002"
+	if got := strings.Join(f.historyEvents(t), " "); got != want {
+		t.Errorf("history %s, want %s", got, want)
+	}
+}
+
+func TestRollbackRefusals(t *testing.T) {
+	f := newActionFixture(t)
+
+	reply := f.click(t, f.handler(f.store), "U2", ActionRollback, "api-00002")
+	if !strings.Contains(reply.Text, "not allowed") {
+		t.Errorf("user off the allowlist answered %+v", reply)
+	}
+
+	reply = f.click(t, f.handler(brokenHistory{f.store}), "U1", ActionRollback, "api-00002")
+	if !strings.Contains(reply.Text, "could not be recorded") {
+		t.Errorf("unrecordable rollback answered %+v", reply)
+	}
+
+	if got := f.serving(t); got != "api-00002=100" {
+		t.Errorf("serving %s after refused rollbacks", got)
+	}
+}
+
+func TestRollbackFailureIsReported(t *testing.T) {
+	f := newActionFixture(t)
+	f.client.FailNext(cloudrun.MethodUpdateService, errors.New("revision api-00001 is not ready"))
+
+	reply := f.click(t, f.handler(f.store), "U1", ActionRollback, "api-00002")
+	if reply.ResponseType != "ephemeral" || !strings.Contains(reply.Text, "failed: updating the traffic") {
+		t.Errorf("reply %+v", reply)
+	}
+
+	want := "rollback-requested:api-00001 rollback-failed:api-00001"
+	if got := strings.Join(f.historyEvents(t), " "); got != want {
+		t.Errorf("history %s, want %s", got, want)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	f := newActionFixture(t)
+
+	reply := f.click(t, f.handler(f.store), "U2", ActionAcknowledge, "api-00002")
+	if reply.ResponseType != "in_channel" || !strings.Contains(reply.Text, "<@U2> acknowledged `api-00002`") {
+		t.Errorf("reply %+v", reply)
+	}
+
+	reply = f.click(t, f.handler(brokenHistory{f.store}), "U2", ActionAcknowledge, "api-00002")
+	if !strings.Contains(reply.Text, "could not be recorded") {
+		t.Errorf("unrecordable acknowledgement answered %+v", reply)
+	}
+}
+
+// sign computes the X-Slack-Signature of a request the way Slack does.
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyRequest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := "payload=%7B%22type%22%3A%22block_actions%22%7D"
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-MaxRequestAge-time.Second).Unix(), 10)
+	future := strconv.FormatInt(now.Add(MaxRequestAge+time.Second).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      string
+		wantErr   string
+	}{
+		{name: "valid", secret: testSigningSecret, timestamp: timestamp, signature: sign(testSigningSecret, timestamp, body), body: body},
+		{name: "slightly early clock", secret: testSigningSecret, timestamp: strconv.FormatInt(now.Unix()+30, 10),
+			signature: sign(testSigningSecret, strconv.FormatInt(now.Unix()+30, 10), body), body: body},
+		{name: "other secret", secret: testSigningSecret, timestamp: timestamp, signature: sign("another secret", timestamp, body), body: body, wantErr: "signature mismatch"},
+		{name: "missing signature", secret: testSigningSecret, timestamp: timestamp, body: body, wantErr: "signature mismatch"},
+		{name: "garbled signature", secret: testSigningSecret, timestamp: timestamp, signature: "v0=not-hex", body: body, wantErr: "signature mismatch"},
+		{name: "signature without version", secret: testSigningSecret, timestamp: timestamp,
+			signature: strings.TrimPrefix(sign(testSigningSecret, timestamp, body), "v0="), body: body, wantErr: "signature mismatch"},
+		{name: "tampered body", secret: testSigningSecret, timestamp: timestamp, signature: sign(testSigningSecret, timestamp, body), body: body + "x", wantErr: "signature mismatch"},
+		{name: "signature of another timestamp", secret: testSigningSecret, timestamp: timestamp, signature: sign(testSigningSecret, stale, body), body: body, wantErr: "signature mismatch"},
+		{name: "missing timestamp", secret: testSigningSecret, signature: sign(testSigningSecret, "", body), body: body, wantErr: "invalid timestamp"},
+		{name: "garbled timestamp", secret: testSigningSecret, timestamp: "yesterday", signature: sign(testSigningSecret, "yesterday", body), body: body, wantErr: "invalid timestamp"},
+		{name: "expired", secret: testSigningSecret, timestamp: stale, signature: sign(testSigningSecret, stale, body), body: body, wantErr: "too far from now"},
+		{name: "future", secret: testSigningSecret, timestamp: future, signature: sign(testSigningSecret, future, body), body: body, wantErr: "too far from now"},
+		{name: "no secret", timestamp: timestamp, signature: sign("", timestamp, body), body: body, wantErr: "no signing secret"},
+	}
+
+	for _, test := range tests {
+		header := http.Header{}
+		if test.timestamp != "" {
+			header.Set("X-Slack-Request-Timestamp", test.timestamp)
+		}
+		if test.signature != "" {
+			header.Set("X-Slack-Signature", test.signature)
+		}
+
+		err := VerifyRequest(test.secret, header, []byte(test.body), now)
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+// untouchable is a client and a store failing the test on any use: the nil interfaces they embed panic.
+type untouchable struct {
+	cloudrun.CloudRunClient
+	StateStore
+}
+
+func TestServeHTTPRefusesUnverifiedRequests(t *testing.T) {
+	body := "payload=" + url.QueryEscape(`{"type":"block_actions","user":{"id":"U1"},"actions":[{"action_id":"rollback","value":"{}"}]}`)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+	}{
+		{name: "bad signature", secret: testSigningSecret, signature: sign("another secret", timestamp, body)},
+		{name: "no signature", secret: testSigningSecret},
+		{name: "no signing secret", signature: sign("", timestamp, body)},
+	}
+
+	for _, test := range tests {
+		var nothing untouchable
+		handler := ActionHandler{SigningSecret: test.secret, Client: nothing, Store: nothing, Allowlist: map[string][]string{"*": {"U1"}}}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+		req.Header.Set("X-Slack-Signature", test.signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: answered %d, want 401", test.name, w.Code)
+		}
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	service := Service{Name: "projects/p/locations/us-central1/services/api", ProjectID: "p", Region: "us-central1"}
+	elsewhere := Service{Name: "projects/q/locations/europe-west1/services/api", ProjectID: "q", Region: "europe-west1"}
+
+	tests := []struct {
+		name      string
+		allowlist map[string][]string
+		service   Service
+		want      bool
+	}{
+		{name: "full key", allowlist: map[string][]string{"p/us-central1/api": {"U1"}}, service: service, want: true},
+		{name: "full key of another project", allowlist: map[string][]string{"p/us-central1/api": {"U1"}}, service: elsewhere},
+		{name: "short name in every project", allowlist: map[string][]string{"api": {"U1"}}, service: elsewhere, want: true},
+		{name: "wildcard", allowlist: map[string][]string{"*": {"U1"}}, service: service, want: true},
+		{name: "other user", allowlist: map[string][]string{"p/us-central1/api": {"U2"}, "*": {"U3"}}, service: service},
+		{name: "empty", service: service},
+	}
+
+	for _, test := range tests {
+		if got := (ActionHandler{Allowlist: test.allowlist}).allowed(test.service, "U1"); got != test.want {
+			t.Errorf("%s: allowed = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
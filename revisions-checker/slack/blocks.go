@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	. "revisions-checker/common"
+	"revisions-checker/notify"
+	"revisions-checker/utils"
+)
+
+// Block Kit limits, see https://api.slack.com/reference/block-kit/blocks
+const (
+	maxHeaderLength  = 150
+	maxSectionLength = 3000
+)
+
+// Block is a Block Kit layout block. Only the properties of the block types used here are modelled.
+type Block struct {
+	Type   string       `json:"type"`
+	Text   *TextObject  `json:"text,omitempty"`
+	Fields []TextObject `json:"fields,omitempty"`
+	// Elements holds TextObjects in context blocks and Buttons in actions blocks
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// TextObject is a "plain_text" or "mrkdwn" composition object.
+type TextObject struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// Button is a button of an actions block, opening URL or sending Value to the interactivity request URL.
+type Button struct {
+	Type     string     `json:"type"`
+	Text     TextObject `json:"text"`
+	URL      string     `json:"url,omitempty"`
+	ActionID string     `json:"action_id"`
+	Value    string     `json:"value,omitempty"`
+	Style    string     `json:"style,omitempty"`
+	Confirm  *Confirm   `json:"confirm,omitempty"`
+}
+
+// Confirm is the dialog shown before a button's action is sent.
+type Confirm struct {
+	Title   TextObject `json:"title"`
+	Text    TextObject `json:"text"`
+	Confirm TextObject `json:"confirm"`
+	Deny    TextObject `json:"deny"`
+	Style   string     `json:"style,omitempty"`
+}
+
+// Payload renders event as Block Kit blocks, with a one-line text fallback for notification previews.
+func Payload(event notify.RevisionEvent) (SlackRequestBody, error) {
+	c, err := describe(event)
+	if err != nil {
+		return SlackRequestBody{}, err
+	}
+
+	return layout(event, c), nil
+}
+
+func layout(event notify.RevisionEvent, c content) SlackRequestBody {
+	blocks := []Block{{Type: "header", Text: &TextObject{Type: "plain_text", Text: truncate(c.title, maxHeaderLength), Emoji: true}}}
+	blocks = append(blocks, Block{Type: "section", Fields: fields(c.revision, event.Service)})
+	for _, section := range c.sections {
+		if section != "" {
+			blocks = append(blocks, Block{Type: "section", Text: mrkdwn(truncate(section, maxSectionLength))})
+		}
+	}
+
+	var notes []interface{}
+	if c.revision != nil && !c.revision.CreationTime.IsZero() {
+		notes = append(notes, mrkdwn("Created "+c.revision.CreationTime.Format(time.RFC1123)))
+	}
+	if !event.Time.IsZero() {
+		notes = append(notes, mrkdwn("Detected "+event.Time.Format(time.RFC1123)))
+	}
+	for _, note := range c.notes {
+		notes = append(notes, mrkdwn(note))
+	}
+	if len(notes) > 0 {
+		blocks = append(blocks, Block{Type: "context", Elements: notes})
+	}
+
+	var buttons []interface{}
+	if c.revision != nil && event.Service.ProjectID != "" {
+		buttons = append(buttons, button("Open revision in console", notify.RevisionConsoleURL(event.Service, c.revision.Name), "open_revision"))
+	}
+	if event.Service.URL != "" {
+		buttons = append(buttons, button("Open service URL", event.Service.URL, "open_service_url"))
+	}
+	if len(buttons) > 0 {
+		blocks = append(blocks, Block{Type: "actions", Elements: buttons})
+	}
+
+	return SlackRequestBody{Text: notify.Summary(event), Blocks: blocks}
+}
+
+// fields lists the revision and where it runs, two columns wide.
+func fields(revision *Revision, service Service) []TextObject {
+	var f []TextObject
+	if revision != nil {
+		f = append(f, *mrkdwn(fmt.Sprintf("*Revision:*\n`%s`", revision.Name)))
+		f = append(f, *mrkdwn(fmt.Sprintf("*Image:*\n`%s`", revision.Image)))
+		if revision.TrafficPercent > 0 {
+			f = append(f, *mrkdwn(fmt.Sprintf("*Traffic:*\n`%d%%`%s", revision.TrafficPercent, tagSuffix(*revision))))
+		}
+	}
+	f = append(f, *mrkdwn(fmt.Sprintf("*Service:*\n`%s`", utils.ExtractShortServiceName(service.Name))))
+	f = append(f, *mrkdwn(fmt.Sprintf("*Project:*\n`%s`", service.ProjectID)))
+	f = append(f, *mrkdwn(fmt.Sprintf("*Region:*\n`%s`", service.Region)))
+
+	return f
+}
+
+func tagSuffix(revision Revision) string {
+	if revision.TrafficTag == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (tag `%s`)", revision.TrafficTag)
+}
+
+func mrkdwn(text string) *TextObject {
+	return &TextObject{Type: "mrkdwn", Text: text}
+}
+
+func button(text, url, actionID string) Button {
+	return Button{Type: "button", Text: TextObject{Type: "plain_text", Text: text}, URL: url, ActionID: actionID}
+}
+
+// truncate shortens text to at most limit characters, marking the cut with an ellipsis.
+func truncate(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+
+	runes := []rune(text)
+	return string(runes[:limit-1]) + "…"
+}
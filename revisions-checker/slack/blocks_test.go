@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestPayloadGolden compares the Block Kit payload of every event kind with testdata/<kind>.golden.
+// Run go test ./slack -update after an intended change of the layout and review the diff.
+func TestPayloadGolden(t *testing.T) {
+	for _, kind := range templates.Kinds {
+		if kind == templates.Default {
+			continue
+		}
+
+		payload, err := Payload(templates.SampleEvent(kind))
+		if err != nil {
+			t.Errorf("%s: %v", kind, err)
+			continue
+		}
+		got, err := json.MarshalIndent(payload, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checkGolden(t, kind+".golden", append(got, '\n'))
+	}
+}
+
+// TestPayloadText compares the text fallback shown in notification previews, which is
+// all that some clients display, with testdata/text.golden.
+func TestPayloadText(t *testing.T) {
+	var got bytes.Buffer
+	for _, kind := range templates.Kinds {
+		if kind == templates.Default {
+			continue
+		}
+
+		event := templates.SampleEvent(kind)
+		payload, err := Payload(event)
+		if err != nil {
+			t.Errorf("%s: %v", kind, err)
+			continue
+		}
+		if payload.Text != notify.Summary(event) {
+			t.Errorf("%s: text %q, want the summary %q", kind, payload.Text, notify.Summary(event))
+		}
+		fmt.Fprintf(&got, "%s: %s\n", kind, payload.Text)
+	}
+
+	checkGolden(t, "text.golden", got.Bytes())
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
@@ -0,0 +1,252 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	. "revisions-checker/state"
+	"revisions-checker/templates"
+)
+
+// DefaultAPIURL is the base URL of the Slack Web API.
+const DefaultAPIURL = "https://slack.com/api/"
+
+// maxThreads bounds the deploy messages remembered per service; older deploys get a new message.
+const maxThreads = 50
+
+// Bot posts revision events with a bot token through the Slack Web API, keeping one living message
+// per deploy: the first event about a revision posts it, later events about the same revision update
+// it in place and are added as replies to its thread. Traffic shifts go to the thread of the newest
+// deploy they involve. Events about no known deploy are posted on their own.
+type Bot struct {
+	Token   string
+	Channel string
+	// APIURL overrides DefaultAPIURL, e.g. for a proxy
+	APIURL    string
+	Templates *templates.Set
+	// Store keeps the timestamps of the deploy messages under KeyPrefix;
+	// without it every event is posted on its own
+	Store     StateStore
+	KeyPrefix string
+	// Interactive adds the Acknowledge and Roll back buttons to new revision messages
+	Interactive bool
+}
+
+// chatMessage holds the arguments of chat.postMessage and chat.update.
+type chatMessage struct {
+	Channel        string  `json:"channel"`
+	TS             string  `json:"ts,omitempty"`
+	ThreadTS       string  `json:"thread_ts,omitempty"`
+	ReplyBroadcast bool    `json:"reply_broadcast,omitempty"`
+	Text           string  `json:"text"`
+	Blocks         []Block `json:"blocks,omitempty"`
+}
+
+// apiResponse is the envelope of Web API answers.
+type apiResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error"`
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+}
+
+func (b Bot) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	payload, err := render(event, b.Templates, b.Interactive)
+	if err != nil {
+		return err
+	}
+
+	if b.Store == nil {
+		_, err := b.call(ctx, "chat.postMessage", chatMessage{Channel: b.Channel, Text: payload.Text, Blocks: payload.Blocks})
+		return err
+	}
+
+	key := DocumentName(b.KeyPrefix, event.Service)
+	messages, err := b.Store.GetMessages(ctx, key)
+	if err != nil {
+		return fmt.Errorf("loading the posted Slack messages: %w", err)
+	}
+	if messages == nil {
+		messages = map[string]PostedMessage{}
+	}
+
+	revision, parentEvent := deployOf(event, messages)
+	if revision == "" {
+		_, err := b.call(ctx, "chat.postMessage", chatMessage{Channel: b.Channel, Text: payload.Text, Blocks: payload.Blocks})
+		return err
+	}
+
+	parent, ok := messages[revision]
+	updated := false
+	if ok && parentEvent != nil {
+		parentPayload, err := render(*parentEvent, b.Templates, b.Interactive)
+		if err != nil {
+			return err
+		}
+		_, err = b.call(ctx, "chat.update", chatMessage{Channel: parent.Channel, TS: parent.Timestamp, Text: parentPayload.Text, Blocks: parentPayload.Blocks})
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "message_not_found" {
+			// Someone deleted the message, start over with a new one
+			ok = false
+		} else if err != nil {
+			return err
+		}
+		if ok {
+			parent.Event = encodeEvent(*parentEvent)
+			messages[revision] = parent
+			updated = true
+		}
+	}
+
+	if !ok {
+		answer, err := b.call(ctx, "chat.postMessage", chatMessage{Channel: b.Channel, Text: payload.Text, Blocks: payload.Blocks})
+		if err != nil || event.Kind == diff.EventRevisionDeleted || event.Kind == diff.EventTrafficShifted {
+			// A deleted revision or a shift away from a forgotten deploy does not start a deploy message
+			return err
+		}
+		messages[revision] = PostedMessage{Channel: answer.Channel, Timestamp: answer.TS, Posted: time.Now().UTC(), Event: encodeEvent(event)}
+		prune(messages)
+		return b.Store.PutMessages(ctx, key, messages)
+	}
+
+	// Failures and rollbacks are worth showing in the channel, not only in the thread
+	broadcast := event.Kind == diff.EventRevisionFailed || event.Kind == diff.EventRollback
+	if _, err := b.call(ctx, "chat.postMessage", chatMessage{Channel: parent.Channel, ThreadTS: parent.Timestamp,
+		ReplyBroadcast: broadcast, Text: payload.Text, Blocks: payload.Blocks}); err != nil {
+		return err
+	}
+
+	if event.Kind == diff.EventRevisionDeleted {
+		delete(messages, revision)
+		updated = true
+	}
+	if updated {
+		return b.Store.PutMessages(ctx, key, messages)
+	}
+
+	return nil
+}
+
+// deployOf returns the revision whose message an event belongs to, and the event the message should
+// show from now on, nil to leave it as it is. Events about a revision belong to that revision;
+// traffic shifts belong to the newest revision with a message that serves traffic before or after them,
+// whose message keeps showing the event it was posted for with the new share of traffic.
+func deployOf(event notify.RevisionEvent, messages map[string]PostedMessage) (string, *notify.RevisionEvent) {
+	if event.Revision.Name != "" {
+		return event.Revision.Name, &event
+	}
+	if event.Kind != diff.EventTrafficShifted {
+		return "", nil
+	}
+
+	var newest string
+	for _, revision := range append(append([]Revision{}, event.TrafficAfter...), event.TrafficBefore...) {
+		if message, ok := messages[revision.Name]; ok && (newest == "" || message.Posted.After(messages[newest].Posted)) {
+			newest = revision.Name
+		}
+	}
+	for _, revision := range event.TrafficAfter {
+		if revision.Name == newest {
+			parent := notify.RevisionEvent{Event: diff.Event{Kind: diff.EventNewActiveRevision, Revision: revision},
+				Service: event.Service, Time: event.Time}
+			if stored := messages[newest].Event; stored != nil {
+				// Messages saved before events were kept fall back to a plain new revision message
+				if err := json.Unmarshal(stored, &parent); err != nil {
+					log.Printf("Ignoring the unreadable event of the Slack message about %s: %v", newest, err)
+				}
+			}
+			parent.Revision.TrafficPercent, parent.Revision.TrafficTag = revision.TrafficPercent, revision.TrafficTag
+			parent.ActiveRevisions = event.ActiveRevisions
+			return newest, &parent
+		}
+	}
+
+	return newest, nil
+}
+
+// encodeEvent keeps what a message shows about an event; the active revisions are left out as
+// they are replaced by the current ones whenever the message is rendered again.
+func encodeEvent(event notify.RevisionEvent) []byte {
+	event.ActiveRevisions = nil
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// prune forgets the oldest messages beyond maxThreads.
+func prune(messages map[string]PostedMessage) {
+	if len(messages) <= maxThreads {
+		return
+	}
+
+	names := make([]string, 0, len(messages))
+	for name := range messages {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return messages[names[i]].Posted.After(messages[names[j]].Posted) })
+	for _, name := range names[maxThreads:] {
+		delete(messages, name)
+	}
+}
+
+// APIError is a Web API call answered with "ok": false, e.g. "channel_not_found" or "ratelimited".
+type APIError struct {
+	Method string
+	Code   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Slack %s failed: %s", e.Method, e.Code)
+}
+
+// call invokes a Web API method with a JSON body and checks the "ok" flag of its answer.
+func (b Bot) call(ctx context.Context, method string, msg chatMessage) (apiResponse, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return apiResponse{}, err
+	}
+
+	apiURL := b.APIURL
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(apiURL, "/")+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return apiResponse{}, notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+b.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return apiResponse{}, notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return apiResponse{}, fmt.Errorf("throttled by Slack (retry after %ss)", resp.Header.Get("Retry-After"))
+	}
+
+	var answer apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return apiResponse{}, fmt.Errorf("Slack %s returned %s with an unreadable body: %w", method, resp.Status, err)
+	}
+	if !answer.OK {
+		return answer, &APIError{Method: method, Code: answer.Error}
+	}
+
+	return answer, nil
+}
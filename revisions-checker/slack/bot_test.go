@@ -0,0 +1,232 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	. "revisions-checker/state"
+	"revisions-checker/templates"
+)
+
+// fakeSlack records the Web API calls of a Bot and answers each post with the next timestamp.
+// Updates are answered with updateError when it is set.
+type fakeSlack struct {
+	calls       []call
+	updateError string
+}
+
+type call struct {
+	method string
+	msg    chatMessage
+}
+
+func (f *fakeSlack) start(t *testing.T) Bot {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg chatMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		f.calls = append(f.calls, call{method: method, msg: msg})
+		if method == "chat.update" && f.updateError != "" {
+			json.NewEncoder(w).Encode(apiResponse{Error: f.updateError})
+			return
+		}
+		json.NewEncoder(w).Encode(apiResponse{OK: true, Channel: "C1", TS: fmt.Sprintf("1700000000.%06d", len(f.calls))})
+	}))
+	t.Cleanup(server.Close)
+
+	return Bot{Token: "xoxb-test", Channel: "C1", APIURL: server.URL, Interactive: true,
+		Store: NewFileStore(filepath.Join(t.TempDir(), "state.json")), KeyPrefix: "messages."}
+}
+
+// take returns the calls made since the last call.
+func (f *fakeSlack) take() []call {
+	calls := f.calls
+	f.calls = nil
+	return calls
+}
+
+func messagesOf(t *testing.T, bot Bot, service Service) map[string]PostedMessage {
+	messages, err := bot.Store.GetMessages(context.Background(), DocumentName(bot.KeyPrefix, service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messages
+}
+
+func TestBotUpdatesTheDeployMessageOnTrafficShifts(t *testing.T) {
+	fake := &fakeSlack{}
+	bot := fake.start(t)
+
+	deploy := templates.SampleEvent(string(diff.EventNewActiveRevision))
+	deploy.Revision.TrafficPercent = 10
+	if err := bot.Notify(context.Background(), deploy); err != nil {
+		t.Fatal(err)
+	}
+
+	previous, revision := *deploy.Previous, deploy.Revision
+	previous.TrafficPercent, revision.TrafficPercent = 50, 50
+	shift := notify.RevisionEvent{
+		Event:   diff.Event{Kind: diff.EventTrafficShifted, TrafficBefore: []Revision{*deploy.Previous, deploy.Revision}, TrafficAfter: []Revision{previous, revision}},
+		Service: deploy.Service,
+	}
+	if err := bot.Notify(context.Background(), shift); err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []chatMessage
+	for _, c := range fake.take() {
+		if c.method == "chat.update" {
+			updates = append(updates, c.msg)
+		}
+	}
+	if len(updates) != 1 {
+		t.Fatalf("got %d chat.update calls, want 1", len(updates))
+	}
+	blocks, _ := json.Marshal(updates[0].Blocks)
+	for _, want := range []string{
+		"New Active Revision",
+		"`50%`",
+		"Configuration Changes vs `sample-00001-abc`",
+		`"action_id":"rollback"`,
+	} {
+		if !strings.Contains(string(blocks), want) {
+			t.Errorf("the updated deploy message lacks %s:\n%s", want, blocks)
+		}
+	}
+}
+
+func TestBotRepliesInTheDeployThread(t *testing.T) {
+	tests := []struct {
+		kind      diff.EventKind
+		broadcast bool
+	}{
+		{diff.EventRevisionFailed, true},
+		{diff.EventRollback, true},
+		{diff.EventRevisionDeactivated, false},
+	}
+
+	for _, test := range tests {
+		fake := &fakeSlack{}
+		bot := fake.start(t)
+
+		if err := bot.Notify(context.Background(), templates.SampleEvent(string(diff.EventNewActiveRevision))); err != nil {
+			t.Fatal(err)
+		}
+		deploy := fake.take()[0].msg
+		if deploy.ThreadTS != "" {
+			t.Errorf("%s: the deploy message was posted in thread %s", test.kind, deploy.ThreadTS)
+		}
+
+		if err := bot.Notify(context.Background(), templates.SampleEvent(string(test.kind))); err != nil {
+			t.Fatal(err)
+		}
+		calls := fake.take()
+		if len(calls) != 2 || calls[0].method != "chat.update" || calls[1].method != "chat.postMessage" {
+			t.Fatalf("%s: got calls %v, want an update and a reply", test.kind, calls)
+		}
+		if update := calls[0].msg; update.TS != "1700000000.000001" {
+			t.Errorf("%s: updated message %s, want the deploy message", test.kind, update.TS)
+		}
+		reply := calls[1].msg
+		if reply.ThreadTS != "1700000000.000001" {
+			t.Errorf("%s: replied in thread %q, want the deploy message", test.kind, reply.ThreadTS)
+		}
+		if reply.ReplyBroadcast != test.broadcast {
+			t.Errorf("%s: reply_broadcast = %v, want %v", test.kind, reply.ReplyBroadcast, test.broadcast)
+		}
+	}
+}
+
+func TestBotRepostsDeletedDeployMessages(t *testing.T) {
+	fake := &fakeSlack{}
+	bot := fake.start(t)
+
+	deploy := templates.SampleEvent(string(diff.EventNewActiveRevision))
+	if err := bot.Notify(context.Background(), deploy); err != nil {
+		t.Fatal(err)
+	}
+	fake.take()
+
+	fake.updateError = "message_not_found"
+	if err := bot.Notify(context.Background(), templates.SampleEvent(string(diff.EventRevisionFailed))); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.take()
+	if len(calls) != 2 || calls[0].method != "chat.update" || calls[1].method != "chat.postMessage" {
+		t.Fatalf("got calls %v, want a failed update and a new post", calls)
+	}
+	if repost := calls[1].msg; repost.ThreadTS != "" || repost.Channel != "C1" {
+		t.Errorf("the new message was posted to %s in thread %q, want a top-level message in C1", repost.Channel, repost.ThreadTS)
+	}
+
+	message := messagesOf(t, bot, deploy.Service)[deploy.Revision.Name]
+	if message.Timestamp != "1700000000.000002" {
+		t.Errorf("remembered message %s, want the new one", message.Timestamp)
+	}
+}
+
+func TestBotPrunesOldThreads(t *testing.T) {
+	fake := &fakeSlack{}
+	bot := fake.start(t)
+
+	deploy := templates.SampleEvent(string(diff.EventNewActiveRevision))
+	old := map[string]PostedMessage{}
+	for i := 0; i < maxThreads; i++ {
+		old[fmt.Sprintf("sample-%05d-old", i)] = PostedMessage{Channel: "C1", Timestamp: fmt.Sprintf("1600000000.%06d", i),
+			Posted: time.Date(2024, 1, 1, 0, i, 0, 0, time.UTC)}
+	}
+	if err := bot.Store.PutMessages(context.Background(), DocumentName(bot.KeyPrefix, deploy.Service), old); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bot.Notify(context.Background(), deploy); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := messagesOf(t, bot, deploy.Service)
+	if len(messages) != maxThreads {
+		t.Errorf("remembered %d messages, want %d", len(messages), maxThreads)
+	}
+	if _, ok := messages["sample-00000-old"]; ok {
+		t.Error("the oldest message was kept")
+	}
+	if _, ok := messages[deploy.Revision.Name]; !ok {
+		t.Error("the new deploy message was not remembered")
+	}
+}
+
+func TestBotForgetsDeletedRevisions(t *testing.T) {
+	fake := &fakeSlack{}
+	bot := fake.start(t)
+
+	deploy := templates.SampleEvent(string(diff.EventNewActiveRevision))
+	if err := bot.Notify(context.Background(), deploy); err != nil {
+		t.Fatal(err)
+	}
+	fake.take()
+
+	deleted := templates.SampleEvent(string(diff.EventRevisionDeleted))
+	if err := bot.Notify(context.Background(), deleted); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.take()
+	if len(calls) != 2 || calls[1].msg.ThreadTS != "1700000000.000001" {
+		t.Errorf("got calls %v, want an update and a reply in the deploy thread", calls)
+	}
+
+	if _, ok := messagesOf(t, bot, deploy.Service)[deploy.Revision.Name]; ok {
+		t.Error("the message of the deleted revision is still remembered")
+	}
+}
@@ -0,0 +1,157 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "revisions-checker/common"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+)
+
+// content is what a message says about an event, independently of its Block Kit layout.
+type content struct {
+	// title is the header, plain text with emoji shortcodes
+	title string
+	// revision fills the fields section; nil for events that are not about a single revision
+	revision *Revision
+	// sections are mrkdwn paragraphs shown below the fields
+	sections []string
+	// notes are shown in the context block at the bottom
+	notes []string
+}
+
+// describe picks the title and the details matching the kind of event.
+func describe(event notify.RevisionEvent) (content, error) {
+	revision := event.Revision
+	switch event.Kind {
+	case diff.EventNewActiveRevision:
+		return content{
+			title:    ":rocket: New Active Revision Detected!",
+			revision: &revision,
+			sections: []string{containersList(revision), containerChangesSection(event.ContainerChanges), specChangesSection(event.Event)},
+			notes:    []string{"Review the new revision, monitor performance and error rates, and ensure that it is operating as expected."},
+		}, nil
+	case diff.EventRollback:
+		details := "Traffic went back to an older revision."
+		if event.Previous != nil {
+			details = fmt.Sprintf("Rolled back from `%s` (image `%s`, created `%s`) to `%s`.",
+				event.Previous.Name, event.Previous.Image, event.Previous.CreationTime.Format(time.RFC1123), event.Revision.Name)
+		}
+		return content{
+			title:    ":rewind: Rollback Detected",
+			revision: &revision,
+			sections: []string{details, containersList(revision), containerChangesSection(event.ContainerChanges), specChangesSection(event.Event)},
+		}, nil
+	case diff.EventRevisionDeactivated:
+		return content{
+			title:    ":zzz: Revision No Longer Serving",
+			revision: &revision,
+			sections: []string{"The revision no longer receives traffic.", containersList(revision)},
+		}, nil
+	case diff.EventTrafficShifted:
+		return content{
+			title:    ":vertical_traffic_light: Traffic Split Changed",
+			sections: []string{"```\n" + notify.TrafficTable(event.TrafficBefore, event.TrafficAfter) + "```"},
+		}, nil
+	case diff.EventActiveRevisionChanged:
+		details := "The serving revision was updated in place."
+		if len(event.ContainerChanges) > 0 {
+			details = containerChangesSection(event.ContainerChanges)
+		} else if event.Previous != nil && event.Previous.Image != event.Revision.Image {
+			details = fmt.Sprintf("Image changed from `%s`.", event.Previous.Image)
+		}
+		return content{
+			title:    ":arrows_counterclockwise: Active Revision Changed",
+			revision: &revision,
+			sections: []string{details, containersList(revision)},
+		}, nil
+	case diff.EventRevisionCreated:
+		return content{
+			title:    ":package: New Revision Created",
+			revision: &revision,
+			sections: []string{"The revision does not receive traffic yet.", containersList(revision)},
+		}, nil
+	case diff.EventRevisionDeleted:
+		return content{
+			title:    ":wastebasket: Revision Deleted",
+			revision: &revision,
+			sections: []string{"The revision is no longer listed by Cloud Run."},
+		}, nil
+	case diff.EventRevisionFailed:
+		return content{
+			title:    ":x: Revision Failed",
+			revision: &revision,
+			sections: []string{"The revision never became ready:\n```\n" + revision.Failure + "\n```"},
+		}, nil
+	case notify.EventMonitoringStarted:
+		return content{
+			title:    ":eyes: Now Monitoring Service",
+			sections: []string{monitoringStartedSection(event.ActiveRevisions)},
+		}, nil
+	default:
+		return content{}, fmt.Errorf("unsupported event kind %q", event.Kind)
+	}
+}
+
+// containersList lists every container image when the revision runs sidecars.
+func containersList(revision Revision) string {
+	if len(revision.Containers) < 2 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("*Containers:*\n")
+	for _, container := range revision.Containers {
+		fmt.Fprintf(&b, "• `%s`: `%s`\n", container.Name, container.Image)
+	}
+	return b.String()
+}
+
+// specChangesSection summarises the configuration diff on one line, followed by the details.
+// Env var values are never shown, only whether they changed.
+func specChangesSection(event diff.Event) string {
+	if len(event.SpecChanges) == 0 || event.Previous == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ":gear: *Configuration Changes vs `%s`:* %d settings (%s)\n```\n",
+		event.Previous.Name, len(event.SpecChanges), strings.Join(diff.SettingGroups(event.SpecChanges), ", "))
+	b.WriteString(notify.SpecChangesText(event.SpecChanges))
+	b.WriteString("```")
+	return b.String()
+}
+
+func containerChangesSection(changes []diff.ContainerChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(":whale: *Container Image Changes:*\n")
+	for _, change := range changes {
+		switch {
+		case change.PreviousImage == "":
+			fmt.Fprintf(&b, "• `%s` added: `%s`\n", change.Name, change.CurrentImage)
+		case change.CurrentImage == "":
+			fmt.Fprintf(&b, "• `%s` removed (was `%s`)\n", change.Name, change.PreviousImage)
+		default:
+			fmt.Fprintf(&b, "• `%s`: `%s` → `%s`\n", change.Name, change.PreviousImage, change.CurrentImage)
+		}
+	}
+	return b.String()
+}
+
+func monitoringStartedSection(activeRevisions []Revision) string {
+	var names []string
+	for _, revision := range activeRevisions {
+		names = append(names, fmt.Sprintf("`%s`", revision.Name))
+	}
+	if len(names) == 0 {
+		names = append(names, "_none_")
+	}
+
+	return "*Active revisions:* " + strings.Join(names, ", ")
+}
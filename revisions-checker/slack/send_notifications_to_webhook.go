@@ -2,61 +2,88 @@ package slack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	. "revisions-checker/common"
-	. "revisions-checker/config"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
 )
 
 // SlackRequestBody is the request payload that Slack expects for an Incoming Webhook.
+// Text is only shown in notifications when Blocks are set.
 type SlackRequestBody struct {
-	Text string `json:"text"`
+	Text   string  `json:"text"`
+	Blocks []Block `json:"blocks,omitempty"`
 }
 
-// SendSlackNotification sends a message to a Slack channel.
-func SendSlackNotification(revision Revision, service Service, config Configuration) error {
-
-	msg := fmt.Sprintf(
-		":rocket: *New Active Revision Detected!*\n\n"+
-			":mag: *Revision Details:*\n"+
-			"- *Name:* `%s`\n"+
-			"- *Image:* `%s`\n"+
-			"- *Creation Time:* `%s`\n"+
-			"- *Service:* `%s`\n\n"+
-			":pushpin: *Additional Info:*\n"+
-			"- *Project ID:* `%s`\n"+
-			"- *Region:* `%s`\n\n"+
-			":link: *View in Cloud Console:* <%s|Link to Cloud Run Service>\n\n"+
-			":loudspeaker: *What's Next?*\n"+
-			"- Review the new revision.\n"+
-			"- Monitor performance and error rates.\n"+
-			"- Ensure that the revision is operating as expected.\n",
-		revision.Name, revision.Image, revision.CreationTime.Format(time.RFC1123),
-		service.Name, config.ProjectID, config.Region, service.URL)
-
-	slackBody, _ := json.Marshal(SlackRequestBody{Text: msg})
-	req, err := http.NewRequest(http.MethodPost, config.SlackWebhookURL, bytes.NewBuffer(slackBody))
+// httpClient bounds the requests to Slack, whether to webhooks or the Web API.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Notifier posts revision events to a Slack Incoming Webhook.
+// Templates, when set, replace the built-in wording below the revision fields.
+// Interactive adds the Acknowledge and Roll back buttons to new revision messages.
+type Notifier struct {
+	WebhookURL  string
+	Templates   *templates.Set
+	Interactive bool
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	payload, err := render(event, n.Templates, n.Interactive)
 	if err != nil {
 		return err
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	return postMessage(ctx, n.WebhookURL, payload)
+}
+
+// render is Payload with the wording of set, when it has a template for the event, and with
+// the interactive buttons when enabled.
+func render(event notify.RevisionEvent, set *templates.Set, interactive bool) (SlackRequestBody, error) {
+	c, err := describe(event)
+	if err != nil {
+		return SlackRequestBody{}, err
+	}
+
+	if text, ok := set.Render(event); ok {
+		c.sections, c.notes = []string{text}, nil
+	}
+
+	payload := layout(event, c)
+	if interactive {
+		addActionButtons(&payload, event)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	return payload, nil
+}
+
+func postMessage(ctx context.Context, webhookURL string, payload SlackRequestBody) error {
+	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
-	if buf.String() != "ok" {
-		return fmt.Errorf("Non-ok response returned from Slack")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
 	}
 	defer resp.Body.Close()
 
+	// Slack answers "ok", or an error code such as "invalid_payload" or "no_service"
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(answer)) != "ok" {
+		return fmt.Errorf("Slack returned %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+
 	return nil
 }
@@ -0,0 +1,84 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"revisions-checker/diff"
+	"revisions-checker/templates"
+)
+
+const testWebhookPath = "/services/T000/B000/secret-token"
+
+func TestNotifierPostsPayload(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+	want, err := Payload(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got SlackRequestBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != testWebhookPath || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got a request to %s with Content-Type %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if err := (Notifier{WebhookURL: server.URL + testWebhookPath}).Notify(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != want.Text || len(got.Blocks) != len(want.Blocks) {
+		t.Errorf("posted %d blocks with text %q, want the %d blocks of Payload", len(got.Blocks), got.Text, len(want.Blocks))
+	}
+}
+
+func TestNotifierErrors(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	tests := []struct {
+		name   string
+		status int
+		answer string
+		closed bool
+		// host replaces the address of the test server when set
+		host    string
+		wantErr string
+	}{
+		{name: "error code", status: http.StatusNotFound, answer: "no_service", wantErr: "Slack returned 404 Not Found: no_service"},
+		{name: "unexpected answer", status: http.StatusOK, answer: "<html>proxy</html>", wantErr: "Slack returned 200 OK: <html>proxy</html>"},
+		{name: "unreachable", closed: true, wantErr: "connection refused"},
+		{name: "unparsable", host: "https://hooks slack.com", wantErr: "invalid URL"},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.answer))
+		}))
+		if test.closed {
+			server.Close()
+		}
+
+		host := server.URL
+		if test.host != "" {
+			host = test.host
+		}
+		err := (Notifier{WebhookURL: host + testWebhookPath}).Notify(context.Background(), event)
+		server.Close()
+
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		} else if strings.Contains(err.Error(), "secret-token") || strings.Contains(err.Error(), server.URL) {
+			t.Errorf("%s: the error leaks the webhook URL: %v", test.name, err)
+		}
+	}
+}
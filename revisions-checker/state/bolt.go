@@ -0,0 +1,146 @@
+package state
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	. "revisions-checker/common"
+	"time"
+)
+
+// BoltStore keeps snapshots in a bbolt database, suited to a long-running self-hosted checker.
+// History lives in a second bucket, with one nested bucket per key whose entries are keyed by sequence number,
+// and posted messages in a third one.
+type BoltStore struct {
+	db       *bolt.DB
+	bucket   []byte
+	history  []byte
+	messages []byte
+}
+
+func NewBoltStore(path, bucket string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening state database %s: %w", path, err)
+	}
+
+	store := &BoltStore{db: db, bucket: []byte(bucket), history: []byte(bucket + ".history"), messages: []byte(bucket + ".messages")}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{store.bucket, store.history, store.messages} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (s *BoltStore) GetSnapshot(ctx context.Context, key string) ([]Revision, error) {
+	var revisions []Revision
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(s.bucket).Get([]byte(key))
+		if data == nil {
+			return fmt.Errorf("%w: %q", ErrSnapshotNotFound, key)
+		}
+		return json.Unmarshal(data, &revisions)
+	})
+
+	return revisions, err
+}
+
+func (s *BoltStore) PutSnapshot(ctx context.Context, key string, revisions []Revision) error {
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put([]byte(key), data)
+	})
+}
+
+func (s *BoltStore) DeleteSnapshot(ctx context.Context, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete([]byte(key))
+	})
+}
+
+func (s *BoltStore) AppendHistory(ctx context.Context, key string, entries []HistoryEntry) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(s.history).CreateBucketIfNotExists([]byte(key))
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(binary.BigEndian.AppendUint64(nil, seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) GetHistory(ctx context.Context, key string) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(s.history).Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+func (s *BoltStore) GetMessages(ctx context.Context, key string) (map[string]PostedMessage, error) {
+	var messages map[string]PostedMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(s.messages).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &messages)
+	})
+
+	return messages, err
+}
+
+func (s *BoltStore) PutMessages(ctx context.Context, key string, messages map[string]PostedMessage) error {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.messages).Put([]byte(key), data)
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,17 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	testStore(t, func() StateStore {
+		store, err := NewBoltStore(path, "revisions")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -0,0 +1,229 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	. "revisions-checker/common"
+	"sync"
+)
+
+// FileStore keeps every snapshot in a single JSON file, suited to local runs and CI.
+// History is appended to a JSON lines file next to it, named after the state file plus ".history",
+// and posted messages are kept in a third file ending in ".messages".
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) GetSnapshot(ctx context.Context, key string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, ok := snapshots[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q in %s", ErrSnapshotNotFound, key, s.path)
+	}
+
+	return revisions, nil
+}
+
+func (s *FileStore) PutSnapshot(ctx context.Context, key string, revisions []Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.read()
+	if err != nil {
+		return err
+	}
+	snapshots[key] = revisions
+
+	return writeJSON(s.path, snapshots)
+}
+
+func (s *FileStore) DeleteSnapshot(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshots, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := snapshots[key]; !ok {
+		return nil
+	}
+	delete(snapshots, key)
+
+	return writeJSON(s.path, snapshots)
+}
+
+// historyLine is one line of the history file.
+type historyLine struct {
+	Key   string
+	Entry HistoryEntry
+}
+
+func (s *FileStore) AppendHistory(ctx context.Context, key string, entries []HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(historyLine{Key: key, Entry: entry})
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	f, err := os.OpenFile(s.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *FileStore) GetHistory(ctx context.Context, key string) ([]HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.historyPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var line historyLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("decoding history file %s: %w", s.historyPath(), err)
+		}
+		if line.Key == key {
+			entries = append(entries, line.Entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func (s *FileStore) historyPath() string {
+	return s.path + ".history"
+}
+
+func (s *FileStore) GetMessages(ctx context.Context, key string) (map[string]PostedMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages, err := s.readMessages()
+	if err != nil {
+		return nil, err
+	}
+
+	return messages[key], nil
+}
+
+func (s *FileStore) PutMessages(ctx context.Context, key string, messages map[string]PostedMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.readMessages()
+	if err != nil {
+		return err
+	}
+	all[key] = messages
+
+	return writeJSON(s.messagesPath(), all)
+}
+
+func (s *FileStore) messagesPath() string {
+	return s.path + ".messages"
+}
+
+func (s *FileStore) readMessages() (map[string]map[string]PostedMessage, error) {
+	messages := map[string]map[string]PostedMessage{}
+
+	data, err := os.ReadFile(s.messagesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return messages, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("decoding messages file %s: %w", s.messagesPath(), err)
+	}
+
+	return messages, nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+func (s *FileStore) read() (map[string][]Revision, error) {
+	snapshots := map[string][]Revision{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snapshots, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("decoding state file %s: %w", s.path, err)
+	}
+
+	return snapshots, nil
+}
+
+// writeJSON replaces the file at path with v, writing to a temporary file first
+// so a crash never leaves a truncated file behind.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
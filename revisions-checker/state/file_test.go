@@ -0,0 +1,11 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	testStore(t, func() StateStore { return NewFileStore(path) })
+}
@@ -0,0 +1,77 @@
+package state
+
+import (
+	. "revisions-checker/common"
+	"sort"
+	"time"
+)
+
+// ServingAt returns the traffic split served at t according to a service history,
+// or nil when the history starts after t. Entries of user actions are skipped.
+func ServingAt(history []HistoryEntry, t time.Time) []TrafficAllocation {
+	var serving []TrafficAllocation
+	for _, entry := range sortedHistory(history) {
+		if entry.Time.After(t) {
+			break
+		}
+		if entry.Actor != "" {
+			continue
+		}
+		serving = entry.Serving
+	}
+
+	return serving
+}
+
+// ServingBefore returns the traffic split served right before revision last started serving according
+// to a service history, or nil when it served as far back as the history goes. Entries of user actions are skipped.
+func ServingBefore(history []HistoryEntry, revision string) []TrafficAllocation {
+	sorted := sortedHistory(history)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].Actor != "" {
+			continue
+		}
+		if !serves(sorted[i].Serving, revision) {
+			return sorted[i].Serving
+		}
+	}
+
+	return nil
+}
+
+func serves(serving []TrafficAllocation, revision string) bool {
+	for _, allocation := range serving {
+		if allocation.Revision == revision && allocation.Percent > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RevisionsEver lists every revision a service history mentions, oldest first.
+func RevisionsEver(history []HistoryEntry) []Revision {
+	var revisions []Revision
+	seen := map[string]bool{}
+	for _, entry := range history {
+		if entry.Revision.Name == "" || seen[entry.Revision.Name] {
+			continue
+		}
+		seen[entry.Revision.Name] = true
+		revisions = append(revisions, entry.Revision)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreationTime.Before(revisions[j].CreationTime)
+	})
+
+	return revisions
+}
+
+func sortedHistory(history []HistoryEntry) []HistoryEntry {
+	sorted := append([]HistoryEntry{}, history...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	return sorted
+}
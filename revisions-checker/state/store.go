@@ -0,0 +1,34 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	. "revisions-checker/common"
+	"revisions-checker/utils"
+)
+
+// StateStore persists the revisions seen during the last check, one snapshot per key,
+// an append-only history of every service and the messages notifiers posted per revision.
+// GetSnapshot returns an error wrapping ErrSnapshotNotFound for keys that were never written,
+// GetHistory and GetMessages return no entries for them.
+type StateStore interface {
+	GetSnapshot(ctx context.Context, key string) ([]Revision, error)
+	PutSnapshot(ctx context.Context, key string, revisions []Revision) error
+	DeleteSnapshot(ctx context.Context, key string) error
+	AppendHistory(ctx context.Context, key string, entries []HistoryEntry) error
+	GetHistory(ctx context.Context, key string) ([]HistoryEntry, error)
+	GetMessages(ctx context.Context, key string) (map[string]PostedMessage, error)
+	PutMessages(ctx context.Context, key string, messages map[string]PostedMessage) error
+	Close() error
+}
+
+// LegacyDocumentName is the key state was saved under before it was namespaced by project and region.
+func LegacyDocumentName(prefix string, service Service) string {
+	return prefix + utils.ExtractShortServiceName(service.Name)
+}
+
+// DocumentName is the key of the state of a service, namespaced by project and region so identical
+// service names don't collide.
+func DocumentName(prefix string, service Service) string {
+	return fmt.Sprintf("%s%s.%s.%s", prefix, service.ProjectID, service.Region, utils.ExtractShortServiceName(service.Name))
+}
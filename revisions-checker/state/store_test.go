@@ -0,0 +1,87 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	. "revisions-checker/common"
+	"testing"
+	"time"
+)
+
+// testStore checks that what a backend saves is read back, also after the store is closed and opened again.
+func testStore(t *testing.T, open func() StateStore) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	revisions := []Revision{
+		{Name: "api-00002", CreationTime: created, Image: "api:2", TrafficPercent: 90, TrafficTag: "stable",
+			Containers: []Container{{Name: "app", Image: "api:2", Ports: []int64{8080}, Resources: map[string]string{"memory": "512Mi"}}}},
+		{Name: "api-00001", CreationTime: created.Add(-time.Hour), Image: "api:1", TrafficPercent: 10},
+	}
+	history := []HistoryEntry{
+		{Time: created, Event: "new-active", Revision: revisions[0], Serving: []TrafficAllocation{{Revision: "api-00002", Percent: 100}}},
+		{Time: created.Add(time.Minute), Event: "acknowledged", Revision: Revision{Name: "api-00002"}, Actor: "slack:U1", Note: "looks good"},
+	}
+	messages := map[string]PostedMessage{"api-00002": {Channel: "C1", Timestamp: "1700000000.000100", Posted: created, Event: []byte(`{"Kind":"new-active"}`)}}
+
+	store := open()
+	if _, err := store.GetSnapshot(ctx, "active.p.r.api"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("GetSnapshot of a missing key: got %v, want ErrSnapshotNotFound", err)
+	}
+	if entries, err := store.GetHistory(ctx, "history.p.r.api"); err != nil || len(entries) != 0 {
+		t.Errorf("GetHistory of a missing key: got %v, %v", entries, err)
+	}
+	if saved, err := store.GetMessages(ctx, "messages.p.r.api"); err != nil || len(saved) != 0 {
+		t.Errorf("GetMessages of a missing key: got %v, %v", saved, err)
+	}
+
+	if err := store.PutSnapshot(ctx, "active.p.r.api", revisions); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutSnapshot(ctx, "active.p.r.other", revisions[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendHistory(ctx, "history.p.r.api", history[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AppendHistory(ctx, "history.p.r.api", history[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PutMessages(ctx, "messages.p.r.api", messages); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = open()
+	defer store.Close()
+	if got, err := store.GetSnapshot(ctx, "active.p.r.api"); err != nil || !reflect.DeepEqual(got, revisions) {
+		t.Errorf("GetSnapshot: got %+v, %v, want %+v", got, err, revisions)
+	}
+	if got, err := store.GetHistory(ctx, "history.p.r.api"); err != nil || !reflect.DeepEqual(got, history) {
+		t.Errorf("GetHistory: got %+v, %v, want the entries in the order they were appended", got, err)
+	}
+	if got, err := store.GetMessages(ctx, "messages.p.r.api"); err != nil || !reflect.DeepEqual(got, messages) {
+		t.Errorf("GetMessages: got %+v, %v, want %+v", got, err, messages)
+	}
+
+	if err := store.PutSnapshot(ctx, "active.p.r.api", revisions[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.GetSnapshot(ctx, "active.p.r.api"); err != nil || len(got) != 1 {
+		t.Errorf("GetSnapshot after an overwrite: got %+v, %v", got, err)
+	}
+	if err := store.DeleteSnapshot(ctx, "active.p.r.api"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetSnapshot(ctx, "active.p.r.api"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("GetSnapshot of a deleted key: got %v, want ErrSnapshotNotFound", err)
+	}
+	if err := store.DeleteSnapshot(ctx, "active.p.r.never"); err != nil {
+		t.Errorf("DeleteSnapshot of a missing key: %v", err)
+	}
+	if got, err := store.GetSnapshot(ctx, "active.p.r.other"); err != nil || len(got) != 1 {
+		t.Errorf("the other snapshot was touched: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,145 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// Notifier posts revision events as Adaptive Cards to a Teams incoming webhook or a Workflows URL.
+// Templates, when set, replace the built-in wording below the revision facts.
+type Notifier struct {
+	WebhookURL string
+	Templates  *templates.Set
+}
+
+// Message is the payload accepted by both incoming webhooks and the "post to a channel
+// when a webhook request is received" workflow.
+type Message struct {
+	Type        string       `json:"type"`
+	Attachments []Attachment `json:"attachments"`
+}
+
+type Attachment struct {
+	ContentType string `json:"contentType"`
+	Content     Card   `json:"content"`
+}
+
+// Card is an Adaptive Card, see https://adaptivecards.io/explorer/.
+type Card struct {
+	Schema  string                   `json:"$schema"`
+	Type    string                   `json:"type"`
+	Version string                   `json:"version"`
+	Body    []map[string]interface{} `json:"body"`
+	Actions []map[string]interface{} `json:"actions,omitempty"`
+	MSTeams map[string]interface{}   `json:"msteams,omitempty"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	text, ok := n.Templates.Render(event)
+	details := notify.Details(event)
+	if ok {
+		details = []notify.Paragraph{{Text: text}}
+	}
+
+	return post(ctx, n.WebhookURL, NewMessage(event, details))
+}
+
+// NewMessage lays out the title, the revision facts, details and console links of an event as an Adaptive Card.
+func NewMessage(event notify.RevisionEvent, details []notify.Paragraph) Message {
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": notify.Title(event), "size": "Large", "weight": "Bolder", "wrap": true},
+		{"type": "TextBlock", "text": notify.Summary(event), "isSubtle": true, "spacing": "None", "wrap": true},
+	}
+
+	var facts []map[string]interface{}
+	for _, fact := range notify.Facts(event) {
+		facts = append(facts, map[string]interface{}{"title": fact.Title, "value": fact.Value})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+
+	for _, paragraph := range details {
+		if !paragraph.Preformatted {
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": paragraph.Text, "wrap": true})
+			continue
+		}
+		// TextBlocks collapse single line breaks, so fixed-width text gets one block per line
+		for i, line := range strings.Split(strings.TrimRight(paragraph.Text, "\n"), "\n") {
+			block := map[string]interface{}{"type": "TextBlock", "text": line, "fontType": "Monospace", "wrap": true}
+			if i > 0 {
+				block["spacing"] = "None"
+			}
+			body = append(body, block)
+		}
+	}
+
+	var actions []map[string]interface{}
+	if event.Revision.Name != "" && event.Service.ProjectID != "" {
+		actions = append(actions, openURL("Open revision in console", notify.RevisionConsoleURL(event.Service, event.Revision.Name)))
+	}
+	if event.Service.URL != "" {
+		actions = append(actions, openURL("Open service URL", event.Service.URL))
+	}
+
+	return Message{
+		Type: "message",
+		Attachments: []Attachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content: Card{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+				Actions: actions,
+				MSTeams: map[string]interface{}{"width": "Full"},
+			},
+		}},
+	}
+}
+
+func openURL(title, url string) map[string]interface{} {
+	return map[string]interface{}{"type": "Action.OpenUrl", "title": title, "url": url}
+}
+
+// post sends msg and interprets the answer. Workflows reply 202 with an empty body, incoming webhooks
+// reply 200 with "1", and the latter also report some delivery failures, e.g. throttling by the
+// Teams backend, as a 200 whose body is an error message.
+func post(ctx context.Context, webhookURL string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	text := strings.TrimSpace(string(answer))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("throttled by Teams (retry after %q): %s", resp.Header.Get("Retry-After"), text)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("Teams returned %s: %s", resp.Status, text)
+	case resp.StatusCode == http.StatusOK && text != "" && text != "1":
+		return fmt.Errorf("Teams did not deliver the message: %s", text)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package teams
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"revisions-checker/templates"
+)
+
+func TestPostStatuses(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		header  map[string]string
+		wantErr string
+	}{
+		{name: "incoming webhook", status: http.StatusOK, body: "1"},
+		{name: "workflow", status: http.StatusAccepted},
+		{name: "200 without a body", status: http.StatusOK},
+		{name: "200 with an error", status: http.StatusOK, body: "Microsoft Teams endpoint returned HTTP error 429",
+			wantErr: "Teams did not deliver the message: Microsoft Teams endpoint returned HTTP error 429"},
+		{name: "throttled", status: http.StatusTooManyRequests, body: "slow down", header: map[string]string{"Retry-After": "30"},
+			wantErr: `throttled by Teams (retry after "30"): slow down`},
+		{name: "bad request", status: http.StatusBadRequest, body: "Summary or Text is required.",
+			wantErr: "Teams returned 400 Bad Request: Summary or Text is required."},
+		{name: "server error", status: http.StatusBadGateway, wantErr: "Teams returned 502 Bad Gateway: "},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("%s: Content-Type %q", test.name, r.Header.Get("Content-Type"))
+			}
+			for key, value := range test.header {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+
+		err := Notifier{WebhookURL: server.URL}.Notify(context.Background(), templates.SampleEvent("new-active"))
+		server.Close()
+
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.wantErr != "" && (err == nil || err.Error() != test.wantErr):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestPostKeepsTheURLOutOfErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	webhookURL := "http://" + address + "/webhookb2/secret-signature"
+	err = Notifier{WebhookURL: webhookURL}.Notify(context.Background(), templates.SampleEvent("new-active"))
+	if err == nil {
+		t.Fatal("posting to a closed port succeeded")
+	}
+	if strings.Contains(err.Error(), "secret-signature") {
+		t.Errorf("error %q reveals the webhook URL", err)
+	}
+}
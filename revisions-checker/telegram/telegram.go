@@ -0,0 +1,170 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+// DefaultEndpoint is the base URL of the Bot API.
+const DefaultEndpoint = "https://api.telegram.org"
+
+// maxMessageLength is the limit of sendMessage texts, counted after entities are parsed.
+const maxMessageLength = 4096
+
+// Notifier sends revision events to a Telegram chat through the Bot API.
+// Templates, when set, replace the built-in wording below the revision facts.
+type Notifier struct {
+	// Endpoint overrides DefaultEndpoint, e.g. for a local Bot API server
+	Endpoint string
+	BotToken string
+	// ChatID is the numeric id of the chat or the @username of a channel
+	ChatID    string
+	Templates *templates.Set
+}
+
+// Message holds the sendMessage parameters, see https://core.telegram.org/bots/api#sendmessage.
+type Message struct {
+	ChatID             string             `json:"chat_id"`
+	Text               string             `json:"text"`
+	ParseMode          string             `json:"parse_mode"`
+	LinkPreviewOptions LinkPreviewOptions `json:"link_preview_options"`
+}
+
+type LinkPreviewOptions struct {
+	IsDisabled bool `json:"is_disabled"`
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	text, ok := n.Templates.Render(event)
+	details := notify.Details(event)
+	if ok {
+		details = []notify.Paragraph{{Text: text}}
+	}
+
+	endpoint := n.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+
+	return send(ctx, strings.TrimRight(endpoint, "/")+"/bot"+n.BotToken+"/sendMessage", Message{
+		ChatID:             n.ChatID,
+		Text:               Text(event, details),
+		ParseMode:          "MarkdownV2",
+		LinkPreviewOptions: LinkPreviewOptions{IsDisabled: true},
+	})
+}
+
+// Text formats the title, revision facts, details and console link of an event in MarkdownV2.
+// Details are left out, starting with the last paragraphs, when the message would be too long.
+func Text(event notify.RevisionEvent, details []notify.Paragraph) string {
+	head := []string{"*" + Escape(notify.Title(event)) + "*"}
+	var facts []string
+	for _, fact := range notify.Facts(event) {
+		facts = append(facts, fmt.Sprintf("%s: `%s`", Escape(fact.Title), EscapeCode(fact.Value)))
+	}
+	head = append(head, strings.Join(facts, "\n"))
+
+	var tail []string
+	if event.Revision.Name != "" && event.Service.ProjectID != "" {
+		tail = append(tail, fmt.Sprintf("[Open revision in console](%s)", EscapeURL(notify.RevisionConsoleURL(event.Service, event.Revision.Name))))
+	} else if event.Service.ProjectID != "" {
+		tail = append(tail, fmt.Sprintf("[Open service in console](%s)", EscapeURL(notify.ServiceConsoleURL(event.Service))))
+	}
+
+	var paragraphs []string
+	for _, paragraph := range details {
+		if paragraph.Preformatted {
+			paragraphs = append(paragraphs, "```\n"+EscapeCode(strings.TrimRight(paragraph.Text, "\n"))+"\n```")
+		} else {
+			paragraphs = append(paragraphs, Escape(paragraph.Text))
+		}
+	}
+
+	// Escapes and markup do not count towards the limit, so this errs on the safe side
+	for ; len(paragraphs) > 0; paragraphs = paragraphs[:len(paragraphs)-1] {
+		text := strings.Join(append(append(append([]string{}, head...), paragraphs...), tail...), "\n\n")
+		if utf8.RuneCountInString(text) <= maxMessageLength {
+			return text
+		}
+	}
+
+	return strings.Join(append(head, tail...), "\n\n")
+}
+
+// Escape backslash-escapes every character MarkdownV2 reserves outside of code entities.
+func Escape(text string) string {
+	return escape(text, "\\_*[]()~`>#+-=|{}.!")
+}
+
+// EscapeCode escapes text for inline code and pre blocks, where only ` and \ are reserved.
+func EscapeCode(text string) string {
+	return escape(text, "\\`")
+}
+
+// EscapeURL escapes the URL part of an inline link, where only ) and \ are reserved.
+func EscapeURL(text string) string {
+	return escape(text, "\\)")
+}
+
+func escape(text, reserved string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(reserved, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// response is the envelope of every Bot API answer.
+type response struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// send calls sendMessage. The Bot API answers every request, failed or not, with a JSON envelope
+// whose "ok" flag is authoritative.
+func send(ctx context.Context, methodURL string, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, methodURL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	var answer response
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return fmt.Errorf("Telegram returned %s with an unreadable body: %w", resp.Status, err)
+	}
+	switch {
+	case answer.OK:
+		return nil
+	case answer.ErrorCode == http.StatusTooManyRequests:
+		return fmt.Errorf("throttled by Telegram (retry after %ds): %s", answer.Parameters.RetryAfter, answer.Description)
+	default:
+		return fmt.Errorf("Telegram returned %d: %s", answer.ErrorCode, answer.Description)
+	}
+}
@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/templates"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const testToken = "123456:secret-token"
+
+// TestTextGolden compares the MarkdownV2 text of every event kind with testdata/<kind>.golden.
+// Run go test ./telegram -update after an intended change of the layout and review the diff.
+func TestTextGolden(t *testing.T) {
+	for _, kind := range templates.Kinds {
+		if kind == templates.Default {
+			continue
+		}
+
+		event := templates.SampleEvent(kind)
+		checkGolden(t, kind+".golden", []byte(Text(event, notify.Details(event))+"\n"))
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		escape func(string) string
+		name   string
+		text   string
+		want   string
+	}{
+		{Escape, "Escape", "api-00002 (100%).", `api\-00002 \(100%\)\.`},
+		{Escape, "Escape", "_*[]()~`>#+-=|{}.!", "\\_\\*\\[\\]\\(\\)\\~\\`\\>\\#\\+\\-\\=\\|\\{\\}\\.\\!"},
+		{Escape, "Escape", `C:\path`, `C:\\path`},
+		{Escape, "Escape", "plain text, 100% & more", "plain text, 100% & more"},
+		{EscapeCode, "EscapeCode", "gcr.io/app:1 `sh -c` C:\\x *bold*", "gcr.io/app:1 \\`sh -c\\` C:\\\\x *bold*"},
+		{EscapeURL, "EscapeURL", "https://console.cloud.google.com/run?project=(p)&x=a\\b", `https://console.cloud.google.com/run?project=(p\)&x=a\\b`},
+	}
+
+	for _, test := range tests {
+		if got := test.escape(test.text); got != test.want {
+			t.Errorf("%s(%q) = %q, want %q", test.name, test.text, got, test.want)
+		}
+	}
+}
+
+func TestLongDetailsAreLeftOut(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventRevisionFailed))
+	details := []notify.Paragraph{
+		{Text: "The revision never became ready:"},
+		{Text: strings.Repeat("log line\n", 600), Preformatted: true},
+	}
+
+	text := Text(event, details)
+	if n := utf8.RuneCountInString(text); n > maxMessageLength {
+		t.Errorf("text of %d characters, over the limit of %d", n, maxMessageLength)
+	}
+	if strings.Contains(text, "log line") || !strings.Contains(text, "became ready") || !strings.Contains(text, "Open revision in console") {
+		t.Errorf("only the paragraphs that do not fit must be left out:\n%s", text)
+	}
+}
+
+func TestSendResponses(t *testing.T) {
+	event := templates.SampleEvent(string(diff.EventNewActiveRevision))
+
+	tests := []struct {
+		name    string
+		status  int
+		answer  string
+		wantErr string
+	}{
+		{name: "sent", status: http.StatusOK, answer: `{"ok":true,"result":{"message_id":1}}`},
+		{name: "bad markup", status: http.StatusBadRequest, answer: `{"ok":false,"error_code":400,"description":"Bad Request: can't parse entities"}`,
+			wantErr: "Telegram returned 400: Bad Request: can't parse entities"},
+		{name: "throttled", status: http.StatusTooManyRequests, answer: `{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":12}}`,
+			wantErr: "throttled by Telegram (retry after 12s): Too Many Requests"},
+		{name: "unreadable", status: http.StatusBadGateway, answer: "<html>bad gateway</html>", wantErr: "Telegram returned 502 Bad Gateway with an unreadable body"},
+	}
+
+	for _, test := range tests {
+		var got Message
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/bot"+testToken+"/sendMessage" {
+				t.Errorf("%s: called %s", test.name, r.URL.Path)
+			}
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.answer))
+		}))
+
+		err := (Notifier{Endpoint: server.URL + "/", BotToken: testToken, ChatID: "@deploys"}).Notify(context.Background(), event)
+		server.Close()
+
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), test.wantErr)):
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+		if got.ChatID != "@deploys" || got.ParseMode != "MarkdownV2" || !got.LinkPreviewOptions.IsDisabled || got.Text != Text(event, notify.Details(event)) {
+			t.Errorf("%s: sent %+v", test.name, got)
+		}
+	}
+}
+
+func TestErrorsKeepTheTokenOut(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	for _, endpoint := range []string{server.URL, "https://api telegram.org"} {
+		err := (Notifier{Endpoint: endpoint, BotToken: testToken, ChatID: "1"}).Notify(context.Background(), templates.SampleEvent(string(diff.EventNewActiveRevision)))
+		if err == nil || strings.Contains(err.Error(), "secret-token") {
+			t.Errorf("sending through %s: got error %v", endpoint, err)
+		}
+	}
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n--- got\n%s\n--- want\n%s", name, got, want)
+	}
+}
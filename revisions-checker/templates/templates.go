@@ -0,0 +1,246 @@
+// Package templates renders user-supplied text/template files as notification wording.
+//
+// Templates are executed with a Data value, so they can refer to:
+//
+//	.Kind           event kind, e.g. "new-active" or "rollback"
+//	.Summary        one-line plain text description of the event
+//	.Revision       the revision the event is about (Name, Image, CreationTime, Containers, TrafficPercent, TrafficTag)
+//	.Previous       the previously serving revision, or nil
+//	.Service        the service (Name, URL, ProjectID, Region, Traffic)
+//	.Event          the full notify.RevisionEvent, e.g. .Event.ContainerChanges or .Event.SpecChanges,
+//	                where env var values only read "(changed)"
+//	.Title          the kind of event in a few words, e.g. "Rollback Detected"
+//	.Facts          labelled values about the revision and the service (Title, Value)
+//	.Details        the built-in description of what changed (Text, Preformatted)
+//	.Configuration  the configuration, with webhook URLs and other secrets blanked
+//
+// along with these functions:
+//
+//	formatTime TIME [LAYOUT]  formats a time, RFC 1123 unless a Go layout is given
+//	shortDigest IMAGE         shortens "repo@sha256:<hex>" to "sha256:<12 hex>", or returns the tag of the image
+//	shortName NAME            the last segment of a resource name
+//	revisionURL               the Cloud Console link of the revision
+//	serviceURL                the Cloud Console link of the service
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	. "revisions-checker/common"
+	"revisions-checker/config"
+	"revisions-checker/diff"
+	"revisions-checker/notify"
+	"revisions-checker/utils"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Default is the key of the template used for event kinds without a template of their own.
+const Default = "default"
+
+// Kinds lists the keys a template can be selected by.
+var Kinds = []string{
+	Default,
+	string(diff.EventNewActiveRevision),
+	string(diff.EventRollback),
+	string(diff.EventRevisionDeactivated),
+	string(diff.EventTrafficShifted),
+	string(diff.EventActiveRevisionChanged),
+	string(diff.EventRevisionCreated),
+	string(diff.EventRevisionDeleted),
+	string(diff.EventRevisionFailed),
+	string(notify.EventMonitoringStarted),
+}
+
+// Data is the value templates are executed with.
+type Data struct {
+	Kind          string
+	Summary       string
+	Revision      Revision
+	Previous      *Revision
+	Service       Service
+	Event         notify.RevisionEvent
+	Title         string
+	Facts         []notify.Fact
+	Details       []notify.Paragraph
+	Configuration config.Configuration
+}
+
+// NewData builds the value templates are executed with; cfg should already be redacted.
+// Masked setting changes are redacted too, in case the event was not built by diff.Specs.
+func NewData(event notify.RevisionEvent, cfg config.Configuration) Data {
+	if len(event.SpecChanges) > 0 {
+		changes := make([]diff.SettingChange, len(event.SpecChanges))
+		for i, change := range event.SpecChanges {
+			changes[i] = change.Redacted()
+		}
+		event.SpecChanges = changes
+	}
+
+	return Data{
+		Kind:          string(event.Kind),
+		Summary:       notify.Summary(event),
+		Revision:      event.Revision,
+		Previous:      event.Previous,
+		Service:       event.Service,
+		Event:         event,
+		Title:         notify.Title(event),
+		Facts:         notify.Facts(event),
+		Details:       notify.Details(event),
+		Configuration: cfg,
+	}
+}
+
+// Set holds the templates of one notifier, keyed by event kind.
+type Set struct {
+	templates     map[string]*template.Template
+	configuration config.Configuration
+}
+
+// Load parses the template files of a notifier and checks that each of them executes
+// against sample data, so mistakes such as unknown fields are reported at startup.
+// A nil Set is returned when files is empty.
+func Load(files map[string]string, cfg config.Configuration) (*Set, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	set := &Set{templates: map[string]*template.Template{}, configuration: cfg.Redacted()}
+	for kind, path := range files {
+		if !IsKind(kind) {
+			return nil, fmt.Errorf("template %s: unknown event kind %q, expected one of %s", path, kind, strings.Join(Kinds, ", "))
+		}
+
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading template: %w", err)
+		}
+		tmpl, err := template.New(filepath.Base(path)).Funcs(Funcs).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("parsing template: %w", err)
+		}
+		set.templates[kind] = tmpl
+
+		// Previous is nil for the first deploy of a service, or a rollback from a forgotten revision
+		sample := SampleEvent(kind)
+		if _, err := set.execute(tmpl, sample); err != nil {
+			return nil, fmt.Errorf("checking template %s: %w", path, err)
+		}
+		sample.Previous = nil
+		if _, err := set.execute(tmpl, sample); err != nil {
+			return nil, fmt.Errorf("checking template %s without a previous revision: %w", path, err)
+		}
+	}
+
+	return set, nil
+}
+
+// Render executes the template selected for the kind of event. ok is false when the Set
+// has no template for it, or the template fails on this event, in which case the notifier
+// keeps its built-in wording: a notification with the default wording beats none at all.
+func (s *Set) Render(event notify.RevisionEvent) (text string, ok bool) {
+	if s == nil {
+		return "", false
+	}
+
+	tmpl, ok := s.templates[string(event.Kind)]
+	if !ok {
+		if tmpl, ok = s.templates[Default]; !ok {
+			return "", false
+		}
+	}
+
+	text, err := s.execute(tmpl, event)
+	if err != nil {
+		log.Printf("Error rendering template %s for a %s event, using the built-in wording: %v", tmpl.Name(), event.Kind, err)
+		return "", false
+	}
+
+	return text, true
+}
+
+func (s *Set) execute(tmpl *template.Template, event notify.RevisionEvent) (string, error) {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, NewData(event, s.configuration)); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// Funcs are the helper functions available to templates.
+var Funcs = template.FuncMap{
+	"formatTime": func(t time.Time, layout ...string) string {
+		if len(layout) > 0 {
+			return t.Format(layout[0])
+		}
+		return t.Format(time.RFC1123)
+	},
+	"shortDigest": ShortDigest,
+	"shortName":   utils.ExtractShortServiceName,
+	"revisionURL": notify.RevisionConsoleURL,
+	"serviceURL":  notify.ServiceConsoleURL,
+}
+
+// ShortDigest shortens "repo@sha256:<hex>" to "sha256:<first 12 hex digits>" and "repo:tag" to "tag".
+func ShortDigest(image string) string {
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		algorithm, hex, _ := strings.Cut(digest, ":")
+		if len(hex) > 12 {
+			hex = hex[:12]
+		}
+		return algorithm + ":" + hex
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[i+1:]
+	}
+	return "latest"
+}
+
+// IsKind reports whether kind is one of Kinds.
+func IsKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// SampleEvent is a fully populated event of the given kind, used to check templates before they are needed.
+func SampleEvent(kind string) notify.RevisionEvent {
+	if kind == Default {
+		kind = string(diff.EventNewActiveRevision)
+	}
+
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := Revision{Name: "sample-00001-abc", CreationTime: created.Add(-time.Hour), Image: "gcr.io/sample/app:1",
+		Containers: []Container{{Name: "app", Image: "gcr.io/sample/app:1"}}}
+	revision := Revision{Name: "sample-00002-def", CreationTime: created, Image: "gcr.io/sample/app:2",
+		Containers: []Container{{Name: "app", Image: "gcr.io/sample/app:2"}}, TrafficPercent: 100}
+	if kind == string(diff.EventRevisionFailed) {
+		revision.TrafficPercent, revision.Failure = 0, "The user-provided container failed to start and listen on the port defined by PORT=8080."
+	}
+	service := Service{Name: "projects/sample/locations/us-central1/services/sample", URL: "https://sample-uc.a.run.app",
+		ProjectID: "sample", Region: "us-central1", Traffic: []TrafficAllocation{{Revision: revision.Name, Percent: 100}}}
+
+	return notify.RevisionEvent{
+		Event: diff.Event{
+			Kind:             diff.EventKind(kind),
+			Revision:         revision,
+			Previous:         &previous,
+			TrafficBefore:    []Revision{previous},
+			TrafficAfter:     []Revision{revision},
+			ContainerChanges: diff.Containers(previous, revision),
+			SpecChanges:      []diff.SettingChange{{Key: "timeout", Previous: "300s", Current: "60s"}},
+		},
+		Service:         service,
+		ActiveRevisions: []Revision{revision},
+		Time:            created.Add(time.Minute),
+	}
+}
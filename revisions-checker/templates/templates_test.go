@@ -0,0 +1,66 @@
+package templates
+
+import (
+	"os"
+	"path/filepath"
+	"revisions-checker/config"
+	"revisions-checker/diff"
+	"testing"
+)
+
+func TestNewDataRedactsEnvValues(t *testing.T) {
+	event := SampleEvent(string(diff.EventActiveRevisionChanged))
+	event.SpecChanges = []diff.SettingChange{
+		{Key: "env/app/TOKEN", Previous: "1a2b3c4d", Current: "5e6f7a8b"},
+		{Key: "env/app/ADDED", Current: "9c0d1e2f"},
+		{Key: "timeout", Previous: "300s", Current: "60s"},
+	}
+
+	data := NewData(event, config.Configuration{})
+
+	want := []diff.SettingChange{
+		{Key: "env/app/TOKEN", Previous: "(changed)", Current: "(changed)"},
+		{Key: "env/app/ADDED", Current: "(changed)"},
+		{Key: "timeout", Previous: "300s", Current: "60s"},
+	}
+	for i, change := range data.Event.SpecChanges {
+		if change != want[i] {
+			t.Errorf("SpecChanges[%d] = %+v, want %+v", i, change, want[i])
+		}
+	}
+	if event.SpecChanges[0].Previous != "1a2b3c4d" {
+		t.Error("NewData modified the event it was given")
+	}
+}
+
+func TestLoadChecksEventsWithoutPrevious(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new-active.tmpl")
+	if err := os.WriteFile(path, []byte("Replaces {{.Previous.Name}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(map[string]string{string(diff.EventNewActiveRevision): path}, config.Configuration{}); err == nil {
+		t.Error("Load accepted a template failing on events without a previous revision")
+	}
+}
+
+func TestRenderFallsBackOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "default.tmpl")
+	if err := os.WriteFile(path, []byte("Serving {{(index .Service.Traffic 0).Revision}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := Load(map[string]string{Default: path}, config.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := SampleEvent(string(diff.EventRevisionCreated))
+	if text, ok := set.Render(event); !ok || text != "Serving sample-00002-def" {
+		t.Errorf("Render = %q, %v", text, ok)
+	}
+
+	event.Service.Traffic = nil
+	if text, ok := set.Render(event); ok {
+		t.Errorf("Render of a failing template = %q, want the built-in wording", text)
+	}
+}
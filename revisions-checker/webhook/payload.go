@@ -0,0 +1,141 @@
+package webhook
+
+import (
+	. "revisions-checker/common"
+	"revisions-checker/notify"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is bumped whenever a field of Payload changes meaning or is removed.
+// Adding fields does not change the version, so receivers should ignore unknown fields.
+const SchemaVersion = "1"
+
+// Payload is the JSON document posted for every event.
+type Payload struct {
+	SchemaVersion string           `json:"schema_version"`
+	Kind          string           `json:"kind"`
+	Time          time.Time        `json:"time"`
+	Summary       string           `json:"summary"`
+	Service       ServicePayload   `json:"service"`
+	Revision      *RevisionPayload `json:"revision,omitempty"`
+	// Previous is the previously serving revision of new active revisions, the revision rolled back
+	// from for rollbacks and the earlier state of a changed revision
+	Previous *RevisionPayload `json:"previous,omitempty"`
+	// Traffic is the split before and after the event, when known
+	Traffic          *TrafficPayload          `json:"traffic,omitempty"`
+	ContainerChanges []ContainerChangePayload `json:"container_changes,omitempty"`
+	SettingChanges   []SettingChangePayload   `json:"setting_changes,omitempty"`
+}
+
+type ServicePayload struct {
+	Name      string `json:"name"`
+	ProjectID string `json:"project_id"`
+	Region    string `json:"region"`
+	URL       string `json:"url,omitempty"`
+}
+
+type RevisionPayload struct {
+	Name           string             `json:"name"`
+	CreatedAt      time.Time          `json:"created_at"`
+	Image          string             `json:"image"`
+	Containers     []ContainerPayload `json:"containers,omitempty"`
+	TrafficPercent int64              `json:"traffic_percent"`
+	TrafficTags    string             `json:"traffic_tags,omitempty"`
+	Failure        string             `json:"failure,omitempty"`
+	ConsoleURL     string             `json:"console_url,omitempty"`
+}
+
+type ContainerPayload struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+type TrafficPayload struct {
+	Before []TrafficEntry `json:"before"`
+	After  []TrafficEntry `json:"after"`
+}
+
+type TrafficEntry struct {
+	Revision string `json:"revision"`
+	Percent  int64  `json:"percent"`
+	Tags     string `json:"tags,omitempty"`
+}
+
+type ContainerChangePayload struct {
+	Container     string `json:"container"`
+	PreviousImage string `json:"previous_image,omitempty"`
+	CurrentImage  string `json:"current_image,omitempty"`
+}
+
+// SettingChangePayload reports a configuration change. Env var values are replaced by "(changed)".
+type SettingChangePayload struct {
+	Key      string `json:"key"`
+	Previous string `json:"previous,omitempty"`
+	Current  string `json:"current,omitempty"`
+}
+
+// NewPayload converts an event to the published schema.
+func NewPayload(event notify.RevisionEvent) Payload {
+	service := event.Service
+	payload := Payload{
+		SchemaVersion: SchemaVersion,
+		Kind:          string(event.Kind),
+		Time:          event.Time,
+		Summary:       notify.Summary(event),
+		Service:       ServicePayload{Name: service.Name, ProjectID: service.ProjectID, Region: service.Region, URL: service.URL},
+	}
+
+	if event.Revision.Name != "" {
+		payload.Revision = revisionPayload(event.Revision, service)
+	}
+	if event.Previous != nil {
+		payload.Previous = revisionPayload(*event.Previous, service)
+	}
+
+	if len(event.TrafficBefore) > 0 || len(event.TrafficAfter) > 0 {
+		payload.Traffic = &TrafficPayload{Before: trafficEntries(event.TrafficBefore), After: trafficEntries(event.TrafficAfter)}
+	} else if len(event.ActiveRevisions) > 0 {
+		payload.Traffic = &TrafficPayload{Before: []TrafficEntry{}, After: trafficEntries(event.ActiveRevisions)}
+	}
+
+	for _, change := range event.ContainerChanges {
+		payload.ContainerChanges = append(payload.ContainerChanges,
+			ContainerChangePayload{Container: change.Name, PreviousImage: change.PreviousImage, CurrentImage: change.CurrentImage})
+	}
+	for _, change := range event.SpecChanges {
+		change = change.Redacted()
+		payload.SettingChanges = append(payload.SettingChanges, SettingChangePayload{Key: change.Key, Previous: change.Previous, Current: change.Current})
+	}
+
+	return payload
+}
+
+func revisionPayload(revision Revision, service Service) *RevisionPayload {
+	payload := &RevisionPayload{
+		Name:           revision.Name,
+		CreatedAt:      revision.CreationTime,
+		Image:          revision.Image,
+		TrafficPercent: revision.TrafficPercent,
+		TrafficTags:    revision.TrafficTag,
+		Failure:        revision.Failure,
+	}
+	if service.ProjectID != "" {
+		payload.ConsoleURL = notify.RevisionConsoleURL(service, revision.Name)
+	}
+	for _, container := range revision.Containers {
+		payload.Containers = append(payload.Containers, ContainerPayload{Name: container.Name, Image: container.Image})
+	}
+
+	return payload
+}
+
+func trafficEntries(revisions []Revision) []TrafficEntry {
+	entries := []TrafficEntry{}
+	for _, revision := range revisions {
+		entries = append(entries, TrafficEntry{Revision: revision.Name, Percent: revision.TrafficPercent, Tags: revision.TrafficTag})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Revision < entries[j].Revision })
+
+	return entries
+}
@@ -0,0 +1,100 @@
+// Package signature signs and verifies the requests of the generic webhook notifier.
+// It only depends on the standard library so receivers can import it on its own.
+//
+// Each request carries the Unix time it was sent at in the TimestampHeader and
+// "v1=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" in the SignatureHeader.
+// Receivers recompute the HMAC with the shared secret and reject old timestamps to prevent replays.
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimestampHeader = "X-RCN-Timestamp"
+	SignatureHeader = "X-RCN-Signature"
+
+	// DefaultTolerance is how old a request may be when verified with a zero tolerance.
+	DefaultTolerance = 5 * time.Minute
+
+	// MaxBodySize bounds the bodies read by VerifyRequest; payloads are a few kilobytes.
+	MaxBodySize = 1 << 20
+
+	scheme = "v1"
+)
+
+var (
+	ErrMissingHeaders   = errors.New("missing signature headers")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpired          = errors.New("timestamp outside the tolerance")
+	ErrBodyTooLarge     = errors.New("body larger than MaxBodySize")
+)
+
+// Sign returns the values of the TimestampHeader and SignatureHeader for body sent at t.
+func Sign(secret string, body []byte, t time.Time) (timestamp, signature string) {
+	timestamp = strconv.FormatInt(t.Unix(), 10)
+	return timestamp, scheme + "=" + compute(secret, timestamp, body)
+}
+
+// Verify checks the signature headers of a request against its body. Requests whose timestamp
+// is further than tolerance away from now are rejected.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, signatures := header.Get(TimestampHeader), header.Get(SignatureHeader)
+	if timestamp == "" || signatures == "" {
+		return ErrMissingHeaders
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: malformed timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+
+	expected := compute(secret, timestamp, body)
+	// Several signatures may be sent while a secret is rotated
+	for _, signature := range strings.Split(signatures, ",") {
+		version, value, _ := strings.Cut(strings.TrimSpace(signature), "=")
+		if version == scheme && hmac.Equal([]byte(value), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// VerifyRequest reads the body of r, up to MaxBodySize, and verifies it, returning the body when the
+// signature is valid.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+
+	return body, Verify(secret, r.Header, body, tolerance)
+}
+
+func compute(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
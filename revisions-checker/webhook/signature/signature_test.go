@@ -0,0 +1,83 @@
+package signature
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testSecret = "whsec_test"
+
+var testBody = []byte(`{"schema_version":"1","kind":"new-active"}`)
+
+func signedHeader(secret string, body []byte, t time.Time) http.Header {
+	timestamp, signature := Sign(secret, body, t)
+	header := http.Header{}
+	header.Set(TimestampHeader, timestamp)
+	header.Set(SignatureHeader, signature)
+	return header
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		header    http.Header
+		body      []byte
+		tolerance time.Duration
+		want      error
+	}{
+		{name: "round trip", header: signedHeader(testSecret, testBody, now), body: testBody},
+		{name: "tampered body", header: signedHeader(testSecret, testBody, now), body: bytes.Replace(testBody, []byte("new-active"), []byte("rollback"), 1), want: ErrInvalidSignature},
+		{name: "other secret", header: signedHeader("whsec_other", testBody, now), body: testBody, want: ErrInvalidSignature},
+		{name: "expired", header: signedHeader(testSecret, testBody, now.Add(-DefaultTolerance-time.Minute)), body: testBody, want: ErrExpired},
+		{name: "from the future", header: signedHeader(testSecret, testBody, now.Add(DefaultTolerance+time.Minute)), body: testBody, want: ErrExpired},
+		{name: "within a custom tolerance", header: signedHeader(testSecret, testBody, now.Add(-time.Hour)), body: testBody, tolerance: 2 * time.Hour},
+		{name: "outside a custom tolerance", header: signedHeader(testSecret, testBody, now.Add(-time.Minute)), body: testBody, tolerance: time.Second, want: ErrExpired},
+		{name: "missing headers", header: http.Header{}, body: testBody, want: ErrMissingHeaders},
+		{name: "malformed timestamp", header: http.Header{http.CanonicalHeaderKey(TimestampHeader): {"soon"}, http.CanonicalHeaderKey(SignatureHeader): {"v1=00"}}, body: testBody, want: ErrInvalidSignature},
+	}
+
+	for _, test := range tests {
+		if err := Verify(testSecret, test.header, test.body, test.tolerance); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestVerifyRotatedSecrets(t *testing.T) {
+	now := time.Now()
+	header := signedHeader(testSecret, testBody, now)
+	_, oldSignature := Sign("whsec_old", testBody, now)
+	header.Set(SignatureHeader, oldSignature+", "+header.Get(SignatureHeader)+", v2=unknown")
+
+	// Receivers still on the old secret and those already on the new one both accept the request
+	for _, secret := range []string{"whsec_old", testSecret} {
+		if err := Verify(secret, header, testBody, 0); err != nil {
+			t.Errorf("verifying with %s: %v", secret, err)
+		}
+	}
+	if err := Verify("whsec_other", header, testBody, 0); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("verifying with another secret: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(testBody))
+	req.Header = signedHeader(testSecret, testBody, time.Now())
+	body, err := VerifyRequest(req, testSecret, 0)
+	if err != nil || !bytes.Equal(body, testBody) {
+		t.Errorf("got %q, %v, want the body", body, err)
+	}
+
+	large := bytes.Repeat([]byte("a"), MaxBodySize+1)
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(large))
+	req.Header = signedHeader(testSecret, large, time.Now())
+	if _, err := VerifyRequest(req, testSecret, 0); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("got %v for a body over MaxBodySize, want ErrBodyTooLarge", err)
+	}
+}
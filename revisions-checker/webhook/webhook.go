@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"revisions-checker/notify"
+	"revisions-checker/webhook/signature"
+)
+
+// DefaultTimeout bounds a delivery when no timeout is configured.
+const DefaultTimeout = 10 * time.Second
+
+// Notifier posts every event as a signed JSON Payload to an HTTP endpoint.
+type Notifier struct {
+	URL string
+	// Secret signs the requests, see package signature
+	Secret  string
+	Headers map[string]string
+	Timeout time.Duration
+}
+
+func (n Notifier) Notify(ctx context.Context, event notify.RevisionEvent) error {
+	body, err := json.Marshal(NewPayload(event))
+	if err != nil {
+		return err
+	}
+
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	for name, value := range n.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-RCN-Schema-Version", SchemaVersion)
+	timestamp, sig := signature.Sign(n.Secret, body, time.Now())
+	req.Header.Set(signature.TimestampHeader, timestamp)
+	req.Header.Set(signature.SignatureHeader, sig)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return notify.RedactURLError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		answer, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(answer)))
+	}
+
+	return nil
+}